@@ -33,8 +33,6 @@ func main() {
 	// Create a new HTTP request multiplexer.
 	mux := http.NewServeMux()
 
-	// Register endpoint handlers.
-
 	// carve out a sub-FS whose root is pkg/html/images
 	imagesSub, err := fs.Sub(embeddedImages, "pkg/html/images")
 	if err != nil {
@@ -43,44 +41,45 @@ func main() {
 
 	// now imagesSub has "favicon.png", etc. at its root
 	imageDir := http.FileServer(http.FS(imagesSub))
-	mux.Handle("/images/", http.StripPrefix("/images/", imageDir))
-
-	mux.HandleFunc("/status", pkg.StatusHandler)
-	// Register endpoints returning attestation text.
-	mux.HandleFunc("/gpu", pkg.MakeAttestationFileHandler(pkg.GPUAttestationFile, "GPU"))
-	mux.HandleFunc("/cpu", pkg.MakeAttestationFileHandler(pkg.CPUAttestationFile, "CPU"))
-	mux.HandleFunc("/self", pkg.MakeAttestationFileHandler(pkg.SelfAttestationFile, "Self"))
-
-	// Register endpoints returning attestation as rendered HTML.
-	mux.HandleFunc("/gpu.html", pkg.MakeAttestationHTMLHandler(pkg.GPUAttestationFile, "GPU"))
-	mux.HandleFunc("/cpu.html", pkg.MakeAttestationHTMLHandler(pkg.CPUAttestationFile, "CPU"))
-	mux.HandleFunc("/self.html", pkg.MakeAttestationHTMLHandler(pkg.SelfAttestationFile, "Self"))
 
-	// Register endpoints for dynamic ITA JWT
-	mux.HandleFunc("/ita-jwt", pkg.MakeItaJwtHandler())
-	mux.HandleFunc("/ita-jwt.html", pkg.MakeItaJwtHTMLHandler())
-
-	// Register endpoints for dynamic Proof of Cloud JWT
-	mux.HandleFunc("/poc-jwt", pkg.MakePocJwtHandler())
-	mux.HandleFunc("/poc-jwt.html", pkg.MakePocJwtHTMLHandler())
-
-	mux.HandleFunc("/logs", pkg.PrivateGuard(pkg.MakeVMLogsHandler(*secure)))
-	mux.HandleFunc("/docker-compose", pkg.PrivateGuard(pkg.MakeDockerComposeFileHandler()))
-	mux.HandleFunc("/docker-compose.html", pkg.PrivateGuard(pkg.MakeDockerComposeHTMLHandler()))
-	mux.HandleFunc("/services", pkg.PrivateGuard(pkg.MakeServicesHandler()))
-	mux.HandleFunc("/vm_upgrades", pkg.PrivateGuard(pkg.MakeVMUpdatesHandler()))
-	mux.HandleFunc("/vm_upgrades.html", pkg.PrivateGuard(pkg.MakeVMUpdatesHTMLHandler()))
-	mux.HandleFunc("/resources", pkg.PrivateGuard(pkg.MakeResourcesHandler()))
-	mux.HandleFunc("/resources.html", pkg.PrivateGuard(pkg.MakeResourcesHTMLHandler()))
+	// Every endpoint is declared once in the route registry; the mux entries,
+	// the /.well-known/ mirror and the access guards are derived from it.
+	registry := pkg.NewRegistry(pkg.DefaultRoutes(*secure)...)
+	registry.Add(pkg.Route{
+		Path:    "/images/",
+		Handler: http.StripPrefix("/images/", imageDir).ServeHTTP,
+		Group:   pkg.GroupPublic,
+		Hidden:  true,
+	})
+	registry.Add(registry.IndexRoutes()...)
+	if err := registry.Mount(mux); err != nil {
+		log.Fatalf("failed to register routes: %v", err)
+	}
 
-	mux.Handle("/publickey_ed25519", pkg.MakePublicKeyHandler(pkg.PublicKeyEd25519Path, "ed25519"))
-	mux.Handle("/publickey_secp256k1", pkg.MakePublicKeyHandler(pkg.PublicKeySecp256k1Path, "secp256k1"))
+	// Sample resource usage in the background so /resources answers at once.
+	go pkg.Resources.Run(context.Background())
 
-	mux.Handle("/publickey_ed25519.html", pkg.MakePublicKeyHTMLHandler(pkg.PublicKeyEd25519Path, "ed25519"))
-	mux.Handle("/publickey_secp256k1.html", pkg.MakePublicKeyHTMLHandler(pkg.PublicKeySecp256k1Path, "secp256k1"))
+	// Record container restarts and health changes for /services/events.
+	go pkg.ContainerEvents.Watch(context.Background())
 
-	// Mirror every registered endpoint under /.well-known/ as well.
-	mux.Handle("/.well-known/", http.StripPrefix("/.well-known", mux))
+	// Keep container and system logs on the secure mount so /logs?history=true
+	// can show them after the containers are gone, and evaluate the alert rules.
+	var store *pkg.LogStore
+	if pkg.LogStoreDir != "" {
+		if store, err = pkg.NewLogStore(pkg.LogStoreDir, pkg.LogStoreSegmentSize, pkg.LogStoreMaxSize); err != nil {
+			log.Printf("Log history disabled: %v", err)
+			store = nil
+		}
+		pkg.LogHistory = store
+	}
+	var alerts *pkg.AlertEngine
+	if pkg.Alerts.Enabled() {
+		alerts = pkg.Alerts
+		go alerts.Run(context.Background())
+	}
+	if store != nil || alerts != nil {
+		go pkg.NewLogCollector(store, alerts, *secure).Run(context.Background())
+	}
 
 	// Apply middleware chain - order matters here
 	// First CORS, then security headers, and finally logging
@@ -105,20 +104,16 @@ func main() {
 		var err error
 
 		if *secure {
-			// Use configuration values for certificate paths
-			certPath := pkg.CertPath
-			keyPath := pkg.KeyPath
-
-			// Check if certificate and key files exist.
-			if _, err := os.Stat(certPath); os.IsNotExist(err) {
-				log.Fatalf("SSL certificate file not found at %s", certPath)
-			}
-			if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-				log.Fatalf("SSL key file not found at %s", keyPath)
+			// Certificates come from CertPath/KeyPath and/or an in-memory
+			// RA-TLS keypair, depending on SECRETVM_RATLS_MODE.
+			tlsConfig, tlsErr := pkg.NewTLSConfig()
+			if tlsErr != nil {
+				log.Fatalf("TLS setup failed: %v", tlsErr)
 			}
+			server.TLSConfig = tlsConfig
 
 			// Start the HTTPS server.
-			err = server.ListenAndServeTLS(certPath, keyPath)
+			err = server.ListenAndServeTLS("", "")
 		} else {
 			// Start the HTTP server.
 			err = server.ListenAndServe()
@@ -0,0 +1,340 @@
+// pkg/alerts.go
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// alertWebhookTimeout bounds a single webhook delivery attempt.
+	alertWebhookTimeout = 10 * time.Second
+	// alertQueueSize bounds the notifications waiting for delivery.
+	alertQueueSize = 100
+	// alertCheckInterval is how often active alerts are checked for resolution.
+	alertCheckInterval = 5 * time.Second
+	// alertSampleBytes bounds the sample message attached to an alert.
+	alertSampleBytes = 1024
+)
+
+// AlertRule fires when Count entries of Source matching Pattern are logged
+// within Window. Rules are configured as a JSON array in SECRETVM_ALERT_RULES:
+//
+//	[{"name": "crash-loop", "source": "web", "pattern": "panic|Traceback", "count": 3, "window": "5m"}]
+type AlertRule struct {
+	Name    string `json:"name"`
+	Source  string `json:"source,omitempty"` // container, "secretvm" or a unit name; empty matches every source
+	Pattern string `json:"pattern"`          // Go regular expression matched against the message
+	Level   string `json:"level,omitempty"`  // optional minimum level
+	Count   int    `json:"count,omitempty"`  // matches needed to fire (default 1)
+	Window  string `json:"window,omitempty"` // duration the matches must fall in (default 5m)
+
+	filter LogFilter // Pattern and Level
+	window time.Duration
+}
+
+// Alert is a firing rule for one source.
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Source    string    `json:"source"`
+	Count     int       `json:"count"`    // matches within the window
+	FiredAt   time.Time `json:"fired_at"` // when the rule started firing
+	LastMatch time.Time `json:"last_match"`
+	Sample    string    `json:"sample"` // last matching message, redacted
+}
+
+// AlertEvent is the JSON body posted to the webhook.
+type AlertEvent struct {
+	Status     string     `json:"status"` // firing or resolved
+	Host       string     `json:"host"`
+	Alert      Alert      `json:"alert"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// alertState tracks the matches of a rule for one source.
+type alertState struct {
+	rule    *AlertRule
+	matches []time.Time // timestamps of matches within the window
+	active  *Alert
+}
+
+// AlertEngine evaluates the rules against log entries and notifies the
+// webhook when an alert fires or resolves.
+type AlertEngine struct {
+	rules      []*AlertRule
+	webhookURL string
+	retries    int
+	backoff    time.Duration // delay before the first retry, doubled each time
+	check      time.Duration // interval of the resolution check
+	client     *http.Client
+	hostName   string
+	started    time.Time
+
+	mu     sync.Mutex
+	states map[string]*alertState // keyed by rule name + "\x00" + source
+	queue  chan AlertEvent
+}
+
+// NewAlertEngine compiles rules. Entries logged before the engine was
+// created, such as backfilled history, are not evaluated.
+func NewAlertEngine(rules []AlertRule, webhookURL string, retries int) (*AlertEngine, error) {
+	hostName, _ := os.Hostname()
+	ae := &AlertEngine{
+		webhookURL: webhookURL,
+		retries:    retries,
+		backoff:    time.Second,
+		check:      alertCheckInterval,
+		client:     &http.Client{Timeout: alertWebhookTimeout},
+		hostName:   hostName,
+		started:    time.Now(),
+		states:     make(map[string]*alertState),
+		queue:      make(chan AlertEvent, alertQueueSize),
+	}
+	names := make(map[string]bool)
+	for i := range rules {
+		rule := rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("alert rule %d: missing name", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("alert rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %s: %w", rule.Name, err)
+		}
+		rule.filter = LogFilter{Grep: re}
+		if rule.Level != "" {
+			if rule.filter.MinLevel = normalizeLevel(rule.Level); rule.filter.MinLevel == "" {
+				return nil, fmt.Errorf("alert rule %s: unknown level %q", rule.Name, rule.Level)
+			}
+		}
+		if rule.Count <= 0 {
+			rule.Count = 1
+		}
+		rule.window = 5 * time.Minute
+		if rule.Window != "" {
+			if rule.window, err = time.ParseDuration(rule.Window); err != nil || rule.window <= 0 {
+				return nil, fmt.Errorf("alert rule %s: invalid window %q", rule.Name, rule.Window)
+			}
+		}
+		ae.rules = append(ae.rules, &rule)
+	}
+	return ae, nil
+}
+
+// newAlertEngine builds the engine from the SECRETVM_ALERT_RULES JSON array;
+// invalid rules are logged and leave the engine without rules.
+func newAlertEngine(rulesJSON, webhookURL string, retries int) *AlertEngine {
+	var rules []AlertRule
+	if rulesJSON != "" {
+		if err := json.Unmarshal([]byte(rulesJSON), &rules); err != nil {
+			log.Printf("Warning: Failed to parse SECRETVM_ALERT_RULES: %v", err)
+			rules = nil
+		}
+	}
+	ae, err := NewAlertEngine(rules, webhookURL, retries)
+	if err != nil {
+		log.Printf("Warning: Invalid SECRETVM_ALERT_RULES: %v", err)
+		ae, _ = NewAlertEngine(nil, webhookURL, retries)
+	}
+	return ae
+}
+
+// Enabled reports whether any rule is configured.
+func (ae *AlertEngine) Enabled() bool {
+	return len(ae.rules) > 0
+}
+
+// Observe evaluates e, logged by source, against every rule.
+func (ae *AlertEngine) Observe(source string, e LogEntry) {
+	if e.Timestamp.Before(ae.started) {
+		return
+	}
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+	for _, rule := range ae.rules {
+		if rule.Source != "" && rule.Source != source && rule.Source != e.Source || !rule.filter.Match(e) {
+			continue
+		}
+		key := rule.Name + "\x00" + source
+		st := ae.states[key]
+		if st == nil {
+			st = &alertState{rule: rule}
+			ae.states[key] = st
+		}
+		st.matches = append(pruneMatches(st.matches, e.Timestamp.Add(-rule.window)), e.Timestamp)
+
+		sample, _ := LogRedactor.Redact(e.Message)
+		if len(sample) > alertSampleBytes {
+			sample = sample[:alertSampleBytes]
+		}
+		if st.active != nil {
+			st.active.Count = len(st.matches)
+			st.active.LastMatch = e.Timestamp
+			st.active.Sample = sample
+			continue
+		}
+		if len(st.matches) >= rule.Count {
+			st.active = &Alert{
+				Rule:      rule.Name,
+				Source:    source,
+				Count:     len(st.matches),
+				FiredAt:   time.Now().UTC(),
+				LastMatch: e.Timestamp,
+				Sample:    sample,
+			}
+			ae.notify(AlertEvent{Status: "firing", Host: ae.hostName, Alert: *st.active})
+		}
+	}
+}
+
+// pruneMatches drops the matches before cutoff.
+func pruneMatches(matches []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(matches) && matches[i].Before(cutoff) {
+		i++
+	}
+	return append(matches[:0], matches[i:]...)
+}
+
+// resolve ends the alerts whose matches fell out of the window by now and
+// forgets sources without recent matches.
+func (ae *AlertEngine) resolve(now time.Time) {
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+	for key, st := range ae.states {
+		st.matches = pruneMatches(st.matches, now.Add(-st.rule.window))
+		if st.active != nil && len(st.matches) < st.rule.Count {
+			resolved := now.UTC()
+			ae.notify(AlertEvent{Status: "resolved", Host: ae.hostName, Alert: *st.active, ResolvedAt: &resolved})
+			st.active = nil
+		}
+		if st.active == nil && len(st.matches) == 0 {
+			delete(ae.states, key)
+		}
+	}
+}
+
+// notify queues ev for the webhook. It is called with ae.mu held and never
+// blocks the log collection; events beyond alertQueueSize are dropped.
+func (ae *AlertEngine) notify(ev AlertEvent) {
+	if ae.webhookURL == "" {
+		return
+	}
+	select {
+	case ae.queue <- ev:
+	default:
+		log.Printf("Alerts: webhook queue full, dropping %s event for %s/%s", ev.Status, ev.Alert.Rule, ev.Alert.Source)
+	}
+}
+
+// Active returns the firing alerts ordered by rule and source.
+func (ae *AlertEngine) Active() []Alert {
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+	alerts := []Alert{}
+	for _, st := range ae.states {
+		if st.active != nil {
+			alerts = append(alerts, *st.active)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].Source < alerts[j].Source
+	})
+	return alerts
+}
+
+// Run delivers queued notifications and resolves alerts until ctx is
+// cancelled. Delivery has its own goroutine, so a slow or unreachable
+// webhook delays notifications but never the resolution of alerts.
+func (ae *AlertEngine) Run(ctx context.Context) {
+	go ae.deliverQueued(ctx)
+	t := time.NewTicker(ae.check)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			ae.resolve(now)
+		}
+	}
+}
+
+// deliverQueued posts queued notifications, in order, until ctx is cancelled.
+func (ae *AlertEngine) deliverQueued(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-ae.queue:
+			if err := ae.deliver(ctx, ev); err != nil {
+				log.Printf("Alerts: webhook delivery of %s/%s failed: %v", ev.Alert.Rule, ev.Alert.Source, err)
+			}
+		}
+	}
+}
+
+// deliver posts ev to the webhook, retrying failed attempts with
+// exponential backoff.
+func (ae *AlertEngine) deliver(ctx context.Context, ev AlertEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	delay := ae.backoff
+	for attempt := 0; ; attempt++ {
+		err = ae.post(ctx, body)
+		if err == nil || attempt >= ae.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (ae *AlertEngine) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ae.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ae.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MakeAlertsHandler implements /alerts: the currently firing alert rules.
+func MakeAlertsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, Alerts.Active())
+	}
+}
@@ -0,0 +1,154 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAlertRulesWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var received []AlertEvent
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			// The first delivery fails and must be retried.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var ev AlertEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("webhook body: %v", err)
+		}
+		received = append(received, ev)
+	}))
+	defer receiver.Close()
+
+	ae, err := NewAlertEngine([]AlertRule{
+		{Name: "crash-loop", Source: "web", Pattern: "panic", Count: 3, Window: "1m"},
+		{Name: "supervisor-errors", Source: "secret-vm-supervisor", Pattern: ".", Level: "error"},
+	}, receiver.URL, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ae.backoff = 10 * time.Millisecond
+	prev := Alerts
+	Alerts = ae
+	defer func() { Alerts = prev }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ae.Run(ctx)
+
+	base := time.Now().Add(time.Minute)
+	entry := func(source, level, msg string, at time.Duration) LogEntry {
+		return LogEntry{Timestamp: base.Add(at), Source: source, Level: level, Message: msg}
+	}
+	// Two panics fall out of the window before the third arrives.
+	ae.Observe("web", entry("web", "", "panic: first", 0))
+	ae.Observe("web", entry("web", "", "panic: second", time.Second))
+	ae.Observe("web", entry("web", "", "panic: third", 2*time.Minute))
+	ae.Observe("other", entry("other", "", "panic: elsewhere", 2*time.Minute))
+	ae.Observe(journalSource, entry("secret-vm-supervisor", LevelInfo, "started", 2*time.Minute))
+	if n := len(ae.Active()); n != 0 {
+		t.Fatalf("%d alerts firing early", n)
+	}
+	ae.Observe("web", entry("web", "", "panic: fourth", 2*time.Minute+10*time.Second))
+	ae.Observe("web", entry("web", "", "panic: token=Bearer abc.def.ghi", 2*time.Minute+20*time.Second))
+	ae.Observe(journalSource, entry("secret-vm-supervisor", LevelError, "ERROR upgrade failed", 2*time.Minute))
+	// Backfilled entries from before the engine started are ignored.
+	ae.Observe("other", LogEntry{Timestamp: time.Now().Add(-time.Hour), Message: "panic: old"})
+
+	active := ae.Active()
+	if len(active) != 2 || active[0].Rule != "crash-loop" || active[0].Source != "web" || active[0].Count != 3 {
+		t.Fatalf("active = %+v", active)
+	}
+	if active[0].Sample != "panic: token=Bearer [REDACTED]" {
+		t.Errorf("sample not redacted: %q", active[0].Sample)
+	}
+
+	rec := httptest.NewRecorder()
+	MakeAlertsHandler()(rec, httptest.NewRequest(http.MethodGet, "/alerts", nil))
+	var listed []Alert
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed) != 2 {
+		t.Errorf("/alerts = %s (%v)", rec.Body, err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || attempts != 3 {
+		t.Fatalf("received %d events in %d attempts", len(received), attempts)
+	}
+	for _, ev := range received {
+		if ev.Status != "firing" || ev.ResolvedAt != nil {
+			t.Errorf("event = %+v", ev)
+		}
+	}
+
+	// Once the matches leave the window, the alerts resolve.
+	ae.resolve(base.Add(8 * time.Minute))
+	if active := ae.Active(); len(active) != 0 {
+		t.Errorf("still firing: %+v", active)
+	}
+}
+
+func TestAlertsResolveWhileWebhookHangs(t *testing.T) {
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer receiver.Close()
+	defer close(release)
+
+	ae, err := NewAlertEngine([]AlertRule{{Name: "any", Pattern: ".", Window: "50ms"}}, receiver.URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ae.check = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ae.Run(ctx)
+
+	ae.Observe("web", LogEntry{Timestamp: time.Now(), Message: "boom"})
+	if len(ae.Active()) != 1 {
+		t.Fatal("alert did not fire")
+	}
+	// The firing notification is stuck at the webhook; resolution goes on.
+	deadline := time.Now().Add(2 * time.Second)
+	for len(ae.Active()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("alert not resolved while the webhook hangs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAlertRulesInvalid(t *testing.T) {
+	for _, rules := range [][]AlertRule{
+		{{Pattern: "x"}},
+		{{Name: "a", Pattern: "("}},
+		{{Name: "a", Pattern: "x", Level: "loud"}},
+		{{Name: "a", Pattern: "x", Window: "-1m"}},
+		{{Name: "a", Pattern: "x"}, {Name: "a", Pattern: "y"}},
+	} {
+		if _, err := NewAlertEngine(rules, "", 0); err == nil {
+			t.Errorf("rules accepted: %+v", rules)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// pkg/audit.go
+package pkg
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Audit outcomes recorded for every request passing through an access guard.
+const (
+	AuditOutcomePublic    = "public"     // private mode is off, endpoint served to anyone
+	AuditOutcomeOpen      = "open"       // endpoint group opened by the endpoints mask
+	AuditOutcomeToken     = "token"      // valid access token presented
+	AuditOutcomeDenied    = "denied"     // missing or invalid token
+	AuditOutcomeLockedOut = "locked_out" // client is locked out after repeated failures
+	AuditOutcomeAction    = "action"     // container control action performed by a token holder
+)
+
+// AuditEntry is a single structured record of an access decision on a guarded endpoint.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Client   string    `json:"client"`
+	Method   string    `json:"method"`
+	Endpoint string    `json:"endpoint"`
+	Identity string    `json:"identity"`
+	Outcome  string    `json:"outcome"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// AuditLog appends entries as JSON lines to a file and keeps the most recent
+// ones in memory so they can be queried without re-reading the file. The file
+// is kept open and rotated once it reaches maxSize, keeping keep rotated files
+// (path.1 being the newest).
+type AuditLog struct {
+	mu      sync.Mutex
+	path    string
+	entries []AuditEntry
+	max     int
+	maxSize int64 // 0 disables rotation
+	keep    int
+	file    *os.File
+	size    int64
+}
+
+// NewAuditLog creates an audit log backed by path, preloading up to max of the
+// most recent entries already present in it and its rotated files.
+func NewAuditLog(path string, max int, maxSize int64, keep int) *AuditLog {
+	a := &AuditLog{path: path, max: max, maxSize: maxSize, keep: keep}
+	a.load()
+	return a
+}
+
+// rotatedPath returns the name of the n-th rotated file; 0 is the live file.
+func (a *AuditLog) rotatedPath(n int) string {
+	if n == 0 {
+		return a.path
+	}
+	return a.path + "." + strconv.Itoa(n)
+}
+
+// load reads existing entries from disk, oldest file first, so history
+// survives a restart.
+func (a *AuditLog) load() {
+	if a.path == "" {
+		return
+	}
+	for n := a.keep; n >= 0; n-- {
+		f, err := os.Open(a.rotatedPath(n))
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var e AuditEntry
+			if json.Unmarshal(sc.Bytes(), &e) != nil {
+				continue
+			}
+			a.appendLocked(e)
+		}
+		f.Close()
+	}
+}
+
+func (a *AuditLog) appendLocked(e AuditEntry) {
+	a.entries = append(a.entries, e)
+	if len(a.entries) > a.max {
+		a.entries = a.entries[len(a.entries)-a.max:]
+	}
+}
+
+// Record stores e in memory and appends it to the audit file.
+func (a *AuditLog) Record(e AuditEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.appendLocked(e)
+
+	if a.path == "" {
+		return
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	if a.file != nil && a.maxSize > 0 && a.size+int64(len(line)) > a.maxSize {
+		a.rotateLocked()
+	}
+	if a.file == nil && !a.openLocked() {
+		return
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		log.Printf("Audit: failed to write %s: %v", a.path, err)
+	}
+}
+
+// openLocked opens the live file for appending.
+func (a *AuditLog) openLocked() bool {
+	if dir := filepath.Dir(a.path); dir != "" {
+		_ = os.MkdirAll(dir, 0700)
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Audit: failed to open %s: %v", a.path, err)
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		log.Printf("Audit: failed to open %s: %v", a.path, err)
+		return false
+	}
+	a.file, a.size = f, fi.Size()
+	return true
+}
+
+// rotateLocked closes the live file and shifts it and the rotated files by
+// one, dropping the oldest. The next Record opens a new live file.
+func (a *AuditLog) rotateLocked() {
+	a.file.Close()
+	a.file, a.size = nil, 0
+	if a.keep <= 0 {
+		_ = os.Remove(a.path)
+		return
+	}
+	_ = os.Remove(a.rotatedPath(a.keep))
+	for n := a.keep - 1; n >= 0; n-- {
+		if err := os.Rename(a.rotatedPath(n), a.rotatedPath(n+1)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Audit: failed to rotate %s: %v", a.rotatedPath(n), err)
+		}
+	}
+}
+
+// AuditQuery filters entries returned by Query. Zero values match everything.
+type AuditQuery struct {
+	Client   string
+	Endpoint string
+	Outcome  string
+	Since    time.Time
+	Limit    int
+}
+
+// Query returns matching entries, newest last, keeping at most q.Limit of the latest.
+func (a *AuditLog) Query(q AuditQuery) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := []AuditEntry{}
+	for _, e := range a.entries {
+		if q.Client != "" && e.Client != q.Client {
+			continue
+		}
+		if q.Endpoint != "" && e.Endpoint != q.Endpoint {
+			continue
+		}
+		if q.Outcome != "" && e.Outcome != q.Outcome {
+			continue
+		}
+		if !q.Since.IsZero() && e.Time.Before(q.Since) {
+			continue
+		}
+		out = append(out, e)
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out
+}
+
+// clientIP returns the peer address of the request without the port.
+// Forwarding headers are deliberately ignored: they are client-controlled
+// and would let an attacker dodge the lockout by rotating them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tokenIdentity describes a presented token without revealing it.
+func tokenIdentity(token string, valid bool) string {
+	switch {
+	case token == "":
+		return "none"
+	case valid:
+		return "dev_token"
+	default:
+		sum := sha256.Sum256([]byte(token))
+		return "invalid:" + hex.EncodeToString(sum[:4])
+	}
+}
+
+// MakeAuditHandler serves recent audit entries as JSON.
+//
+// - ?client=<ip>, ?endpoint=<path>, ?outcome=<outcome> filter entries
+// - ?since=<RFC3339> returns only newer entries
+// - ?limit=<n> caps the number of entries returned (default 500)
+func MakeAuditHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+
+		q := r.URL.Query()
+		query := AuditQuery{
+			Client:   q.Get("client"),
+			Endpoint: q.Get("endpoint"),
+			Outcome:  q.Get("outcome"),
+			Limit:    500,
+		}
+		if l := q.Get("limit"); l != "" {
+			v, err := strconv.Atoi(l)
+			if err != nil || v <= 0 {
+				respondWithError(w, http.StatusBadRequest, "Invalid limit", "limit must be a positive integer")
+				return
+			}
+			query.Limit = v
+		}
+		if s := q.Get("since"); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid since", "since must be an RFC3339 timestamp")
+				return
+			}
+			query.Since = t
+		}
+
+		respondWithJSON(w, http.StatusOK, Audit.Query(query))
+	}
+}
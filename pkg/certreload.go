@@ -0,0 +1,151 @@
+// pkg/certreload.go
+package pkg
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CertInfo describes the certificate currently served.
+type CertInfo struct {
+	Source            string     `json:"source"`
+	Subject           string     `json:"subject"`
+	FingerprintSHA256 string     `json:"fingerprint_sha256"`
+	NotBefore         time.Time  `json:"not_before"`
+	NotAfter          time.Time  `json:"not_after"`
+	LoadedAt          time.Time  `json:"loaded_at"`
+	LastError         string     `json:"last_error,omitempty"`
+	LastErrorAt       *time.Time `json:"last_error_at,omitempty"`
+}
+
+func newCertInfo(source string, leaf *x509.Certificate) CertInfo {
+	sum := sha256.Sum256(leaf.Raw)
+	return CertInfo{
+		Source:            source,
+		Subject:           leaf.Subject.String(),
+		FingerprintSHA256: hex.EncodeToString(sum[:]),
+		NotBefore:         leaf.NotBefore,
+		NotAfter:          leaf.NotAfter,
+		LoadedAt:          time.Now().UTC(),
+	}
+}
+
+// CertReloader serves a certificate/key pair from disk and swaps it
+// atomically when the files change. A replacement that fails validation is
+// ignored and the previous certificate keeps being served.
+type CertReloader struct {
+	certPath string
+	keyPath  string
+	cert     atomic.Pointer[tls.Certificate]
+
+	mu      sync.Mutex
+	info    CertInfo
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// NewCertReloader loads the initial pair; it fails if that pair is invalid.
+func NewCertReloader(certPath, keyPath string) (*CertReloader, error) {
+	c := &CertReloader{certPath: certPath, keyPath: keyPath}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
+
+// Info returns details of the certificate currently served and the last reload error.
+func (c *CertReloader) Info() CertInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info
+}
+
+// loadCertPair reads and validates a certificate/key pair: the key must match
+// the certificate and the certificate must be valid now.
+func loadCertPair(certPath, keyPath string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate pair: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	now := time.Now()
+	if now.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+	if now.Before(leaf.NotBefore) {
+		return nil, fmt.Errorf("certificate not valid before %s", leaf.NotBefore.Format(time.RFC3339))
+	}
+	cert.Leaf = leaf
+	return &cert, nil
+}
+
+// Reload validates the files and swaps the served certificate on success.
+func (c *CertReloader) Reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.certMod, c.keyMod = modTime(c.certPath), modTime(c.keyPath)
+	cert, err := loadCertPair(c.certPath, c.keyPath)
+	if err != nil {
+		now := time.Now().UTC()
+		c.info.LastError, c.info.LastErrorAt = err.Error(), &now
+		return err
+	}
+	c.cert.Store(cert)
+	c.info = newCertInfo(c.certPath, cert.Leaf)
+	return nil
+}
+
+// changed reports whether either file was modified since the last reload attempt.
+func (c *CertReloader) changed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !modTime(c.certPath).Equal(c.certMod) || !modTime(c.keyPath).Equal(c.keyMod)
+}
+
+// Watch polls the certificate and key files every interval and reloads them on change.
+func (c *CertReloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+			if err := c.Reload(); err != nil {
+				log.Printf("TLS: keeping current certificate, reload of %s failed: %v", c.certPath, err)
+				continue
+			}
+			info := c.Info()
+			log.Printf("TLS: reloaded certificate %s (sha256 %s, expires %s)",
+				c.certPath, info.FingerprintSHA256, info.NotAfter.Format(time.RFC3339))
+		}
+	}
+}
+
+func modTime(path string) time.Time {
+	st, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return st.ModTime()
+}
@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"secret-vm-attest-rest-server/pkg/ratls"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and its key as PEM files.
+func writeTestCert(t *testing.T, certPath, keyPath, cn string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certPath != "" {
+		if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if keyPath != "" {
+		if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	writeTestCert(t, certPath, keyPath, "first", time.Now().Add(time.Hour))
+
+	c, err := NewCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := c.Info().FingerprintSHA256
+	if b, _ := json.Marshal(c.Info()); strings.Contains(string(b), "last_error") {
+		t.Errorf("error fields reported without an error: %s", b)
+	}
+
+	// New certificate with a key that does not match: keep serving the old one.
+	writeTestCert(t, certPath, "", "mismatched", time.Now().Add(time.Hour))
+	if err := c.Reload(); err == nil {
+		t.Fatal("mismatched key accepted")
+	}
+	if info := c.Info(); info.FingerprintSHA256 != first || info.LastError == "" || info.LastErrorAt == nil {
+		t.Errorf("after failed reload: %+v", info)
+	}
+
+	// Expired pair: rejected.
+	writeTestCert(t, certPath, keyPath, "expired", time.Now().Add(-time.Hour))
+	if err := c.Reload(); err == nil {
+		t.Fatal("expired certificate accepted")
+	}
+
+	// Valid replacement: swapped in.
+	writeTestCert(t, certPath, keyPath, "second", time.Now().Add(time.Hour))
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := c.GetCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "second" || c.Info().FingerprintSHA256 == first {
+		t.Errorf("certificate not swapped: %s", cert.Leaf.Subject.CommonName)
+	}
+}
+
+func TestRATLSIssuerRenews(t *testing.T) {
+	var calls atomic.Int32
+	failing := atomic.Bool{}
+	issuer, err := NewRATLSIssuer(func() (tls.Certificate, error) {
+		calls.Add(1)
+		if failing.Load() {
+			return tls.Certificate{}, errors.New("quote unavailable")
+		}
+		fakeQuote := func(rd [64]byte) ([]byte, error) { return rd[:], nil }
+		// NotBefore is backdated by a minute, so two thirds of the lifetime
+		// have passed at issue and every certificate is due for renewal.
+		return ratls.NewCertificate(fakeQuote, []string{"localhost"}, 3*time.Second)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := issuer.Info().FingerprintSHA256
+	served := issuer.GetCertificate()
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		issuer.Watch(stop)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for issuer.Info().FingerprintSHA256 == first {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate not renewed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if issuer.GetCertificate() == served || calls.Load() < 2 {
+		t.Error("renewed certificate not served")
+	}
+	close(stop)
+	<-done
+
+	// A failed renewal keeps the current certificate and reports the error.
+	failing.Store(true)
+	current := issuer.Info().FingerprintSHA256
+	if err := issuer.Renew(); err == nil {
+		t.Fatal("renewal did not fail")
+	}
+	if info := issuer.Info(); info.FingerprintSHA256 != current || info.LastError != "quote unavailable" {
+		t.Errorf("after failed renewal: %+v", info)
+	}
+}
@@ -0,0 +1,262 @@
+// pkg/cgroups.go
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContainerResources is the usage of one running container in
+// /resources?containers=true, read from its cgroup v2 files.
+type ContainerResources struct {
+	Name             string  `json:"name"`
+	ID               string  `json:"id"`
+	CPUPercent       float64 `json:"cpu_percent"`                  // 100 = one full CPU
+	MemoryUsedBytes  uint64  `json:"memory_used_bytes"`            // excluding reclaimable page cache
+	MemoryLimitBytes uint64  `json:"memory_limit_bytes,omitempty"` // absent without a limit
+	MemoryPercent    float64 `json:"memory_percent"`               // of the limit, or of the VM's memory without one
+	NetRxBytes       uint64  `json:"net_rx_bytes"`
+	NetTxBytes       uint64  `json:"net_tx_bytes"`
+	BlockReadBytes   uint64  `json:"block_read_bytes"`
+	BlockWriteBytes  uint64  `json:"block_write_bytes"`
+	Error            string  `json:"error,omitempty"` // set when the cgroup could not be read
+}
+
+// cgroupStats is a reading of a container's cgroup at one point in time.
+type cgroupStats struct {
+	at          time.Time
+	cpuUsec     uint64
+	memory      uint64
+	memoryLimit uint64
+	netRx       uint64
+	netTx       uint64
+	blockRead   uint64
+	blockWrite  uint64
+}
+
+// containerCgroupDir finds the cgroup of a container under CgroupRoot, for
+// both the systemd and the cgroupfs cgroup driver of Docker.
+func containerCgroupDir(id string) (string, error) {
+	for _, dir := range []string{
+		filepath.Join(CgroupRoot, "system.slice", "docker-"+id+".scope"),
+		filepath.Join(CgroupRoot, "docker", id),
+	} {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 directory for container %.12s under %s", id, CgroupRoot)
+}
+
+// readCgroupStats reads the CPU, memory and block I/O counters of a cgroup
+// and the network counters of its first process.
+func readCgroupStats(dir string) (*cgroupStats, error) {
+	s := &cgroupStats{at: time.Now()}
+	cpu, err := readKeyedFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	s.cpuUsec = cpu["usage_usec"]
+
+	if s.memory, err = readUintFile(filepath.Join(dir, "memory.current")); err != nil {
+		return nil, err
+	}
+	// Like `docker stats`, leave out page cache the kernel can reclaim.
+	if mstat, err := readKeyedFile(filepath.Join(dir, "memory.stat")); err == nil && mstat["inactive_file"] < s.memory {
+		s.memory -= mstat["inactive_file"]
+	}
+	// "max" means unlimited and leaves the limit at 0.
+	s.memoryLimit, _ = readUintFile(filepath.Join(dir, "memory.max"))
+
+	if err := readIOStat(filepath.Join(dir, "io.stat"), s); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if pid, err := firstCgroupPid(dir); err == nil {
+		s.netRx, s.netTx, _ = readNetDev(filepath.Join(ProcRoot, pid, "net", "dev"))
+	}
+	return s, nil
+}
+
+// readKeyedFile parses "key value" lines as in cpu.stat and memory.stat.
+func readKeyedFile(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	out := make(map[string]uint64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64); err == nil {
+			out[k] = n
+		}
+	}
+	return out, sc.Err()
+}
+
+// readUintFile reads a file holding a single number.
+func readUintFile(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readIOStat sums the bytes read and written on all devices in io.stat,
+// whose lines look like "8:0 rbytes=1024 wbytes=0 rios=1 wios=0 ...".
+func readIOStat(path string, s *cgroupStats) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, field := range strings.Fields(line) {
+			k, v, _ := strings.Cut(field, "=")
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch k {
+			case "rbytes":
+				s.blockRead += n
+			case "wbytes":
+				s.blockWrite += n
+			}
+		}
+	}
+	return nil
+}
+
+// firstCgroupPid returns a process of the cgroup, whose network namespace is
+// the container's.
+func firstCgroupPid(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return "", err
+	}
+	pid, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	if pid == "" {
+		return "", fmt.Errorf("cgroup %s has no processes", dir)
+	}
+	return pid, nil
+}
+
+// readNetDev sums the received and transmitted bytes of all interfaces but
+// loopback in a /proc/<pid>/net/dev file.
+func readNetDev(path string) (rx, tx uint64, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		iface, counters, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		f := strings.Fields(counters)
+		if len(f) < 9 {
+			continue
+		}
+		r, err1 := strconv.ParseUint(f[0], 10, 64)
+		t, err2 := strconv.ParseUint(f[8], 10, 64)
+		if err1 == nil && err2 == nil {
+			rx, tx = rx+r, tx+t
+		}
+	}
+	return rx, tx, nil
+}
+
+// containerSampler reads the cgroups of the running containers twice, so
+// CPU usage can be computed over the interval in between.
+type containerSampler struct {
+	containers []DockerContainer
+	dirs       map[string]string // container ID -> cgroup directory
+	errs       map[string]error
+	before     map[string]*cgroupStats
+}
+
+// startContainerSampler lists the running containers and takes the first reading.
+func startContainerSampler(ctx context.Context) (*containerSampler, error) {
+	all, err := Docker.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	var containers []DockerContainer
+	for _, c := range all {
+		if c.State == "running" {
+			containers = append(containers, c)
+		}
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name() < containers[j].Name() })
+	cs := &containerSampler{
+		containers: containers,
+		dirs:       make(map[string]string),
+		errs:       make(map[string]error),
+		before:     make(map[string]*cgroupStats),
+	}
+	for _, c := range containers {
+		dir, err := containerCgroupDir(c.ID)
+		if err == nil {
+			cs.dirs[c.ID] = dir
+			cs.before[c.ID], err = readCgroupStats(dir)
+		}
+		if err != nil {
+			cs.errs[c.ID] = err
+		}
+	}
+	return cs, nil
+}
+
+// finish takes the second reading. vmMemory is used for the memory
+// percentage of containers without a limit.
+func (cs *containerSampler) finish(vmMemory uint64) []ContainerResources {
+	out := make([]ContainerResources, 0, len(cs.containers))
+	for _, c := range cs.containers {
+		res := ContainerResources{Name: c.Name(), ID: c.ID}
+		err := cs.errs[c.ID]
+		var after *cgroupStats
+		if err == nil {
+			after, err = readCgroupStats(cs.dirs[c.ID])
+		}
+		if err != nil {
+			res.Error = err.Error()
+			out = append(out, res)
+			continue
+		}
+		before := cs.before[c.ID]
+		if elapsed := after.at.Sub(before.at).Microseconds(); elapsed > 0 && after.cpuUsec >= before.cpuUsec {
+			res.CPUPercent = round2(float64(after.cpuUsec-before.cpuUsec) / float64(elapsed) * 100)
+		}
+		res.MemoryUsedBytes = after.memory
+		res.MemoryLimitBytes = after.memoryLimit
+		limit := after.memoryLimit
+		if limit == 0 || limit > vmMemory && vmMemory > 0 {
+			limit = vmMemory
+		}
+		if limit > 0 {
+			res.MemoryPercent = round2(float64(after.memory) / float64(limit) * 100)
+		}
+		res.NetRxBytes, res.NetTxBytes = after.netRx, after.netTx
+		res.BlockReadBytes, res.BlockWriteBytes = after.blockRead, after.blockWrite
+		out = append(out, res)
+	}
+	return out
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestContainerResources(t *testing.T) {
+	startFakeDocker(t, fakeDockerMux(t))
+	root, proc := t.TempDir(), t.TempDir()
+	prevCgroup, prevProc := CgroupRoot, ProcRoot
+	CgroupRoot, ProcRoot = root, proc
+	defer func() { CgroupRoot, ProcRoot = prevCgroup, prevProc }()
+
+	// The fake daemon runs "web" (aaa), managed by the systemd cgroup driver.
+	web := filepath.Join(root, "system.slice", "docker-aaa.scope")
+	writeFiles(t, web, map[string]string{
+		"cpu.stat":       "usage_usec 1000000\nuser_usec 800000\n",
+		"memory.current": "209715200\n",
+		"memory.stat":    "anon 100\ninactive_file 104857600\n",
+		"memory.max":     "419430400\n",
+		"io.stat":        "8:0 rbytes=4096 wbytes=1024 rios=1 wios=1\n8:16 rbytes=4096 wbytes=0 rios=1 wios=0\n",
+		"cgroup.procs":   "4242\n4243\n",
+	})
+	writeFiles(t, proc, map[string]string{"4242/net/dev": `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    9999      10    0    0    0     0          0         0     9999      10    0    0    0     0       0          0
+  eth0:    1500      12    0    0    0     0          0         0      700       8    0    0    0     0       0          0
+`})
+
+	cs, err := startContainerSampler(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pretend the container used half a CPU over one second.
+	before := cs.before["aaa"]
+	before.at = before.at.Add(-1e9)
+	before.cpuUsec -= 500000
+
+	got := cs.finish(1 << 30)
+	if len(got) != 1 {
+		t.Fatalf("containers = %+v", got)
+	}
+	r := got[0]
+	if r.Name != "web" || r.Error != "" {
+		t.Fatalf("web = %+v", r)
+	}
+	if r.CPUPercent < 45 || r.CPUPercent > 55 {
+		t.Errorf("cpu_percent = %v, want ~50", r.CPUPercent)
+	}
+	if r.MemoryUsedBytes != 100<<20 || r.MemoryLimitBytes != 400<<20 || r.MemoryPercent != 25 {
+		t.Errorf("memory = %d / %d (%v%%)", r.MemoryUsedBytes, r.MemoryLimitBytes, r.MemoryPercent)
+	}
+	if r.NetRxBytes != 1500 || r.NetTxBytes != 700 || r.BlockReadBytes != 8192 || r.BlockWriteBytes != 1024 {
+		t.Errorf("io = %+v", r)
+	}
+
+	// Without a limit the percentage is of the VM's memory; a vanished
+	// cgroup is reported per container.
+	writeFiles(t, web, map[string]string{"memory.max": "max\n"})
+	if r := cs.finish(400 << 20)[0]; r.MemoryLimitBytes != 0 || r.MemoryPercent != 25 {
+		t.Errorf("unlimited = %+v", r)
+	}
+	os.RemoveAll(web)
+	if r := cs.finish(1 << 30)[0]; r.Error == "" {
+		t.Errorf("missing cgroup not reported: %+v", r)
+	}
+}
@@ -0,0 +1,424 @@
+// pkg/compose.go
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeDigestHeader carries the SHA-256 of the compose file as stored on
+// disk, also when the response body is masked.
+const ComposeDigestHeader = "X-Compose-SHA256"
+
+// secretEnvKeyRegex matches environment variable names whose values are
+// masked by ?mask=true.
+var secretEnvKeyRegex = regexp.MustCompile(`(?i)(pass(word|wd|phrase)?|secret|token|api_?key|private_?key|access_?key|credential|auth|mnemonic|seed|salt|cookie|session|dsn|database_url)`)
+
+// composeFile is the part of a compose file the server interprets.
+type composeFile struct {
+	Name     string                    `yaml:"name"`
+	Services map[string]composeService `yaml:"services"`
+	Volumes  map[string]yaml.Node      `yaml:"volumes"`
+	Networks map[string]yaml.Node      `yaml:"networks"`
+}
+
+type composeService struct {
+	Image         string      `yaml:"image"`
+	ContainerName string      `yaml:"container_name"`
+	Build         yaml.Node   `yaml:"build"`
+	Ports         []yaml.Node `yaml:"ports"`
+	Volumes       []yaml.Node `yaml:"volumes"`
+	Environment   yaml.Node   `yaml:"environment"`
+	EnvFile       yaml.Node   `yaml:"env_file"`
+}
+
+// parseComposeFile reads the services of a compose file.
+func parseComposeFile(content []byte) (*composeFile, error) {
+	var cf composeFile
+	if err := yaml.Unmarshal(content, &cf); err != nil {
+		return nil, err
+	}
+	return &cf, nil
+}
+
+// composeProjectNameRegex matches the characters Compose drops from project names.
+var composeProjectNameRegex = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// project returns the Compose project name of the file at DockerComposePath:
+// its top-level name, else COMPOSE_PROJECT_NAME, else the name of the
+// directory holding it, normalized the way Compose does.
+func (cf *composeFile) project() string {
+	name := cf.Name
+	if name == "" {
+		name = os.Getenv("COMPOSE_PROJECT_NAME")
+	}
+	if name == "" {
+		name = filepath.Base(filepath.Dir(DockerComposePath))
+	}
+	return strings.TrimLeft(composeProjectNameRegex.ReplaceAllString(strings.ToLower(name), ""), "_-")
+}
+
+// serviceOf returns the compose service a container runs, or "" if it is not
+// part of the compose project. Containers of a same-named service in another
+// project on the VM do not count.
+func (cf *composeFile) serviceOf(c DockerContainer) string {
+	project := cf.project()
+	for name, svc := range cf.Services {
+		if svc.ContainerName != "" && c.Name() == svc.ContainerName {
+			return name
+		}
+		if c.Labels[composeServiceLabel] == name && c.Labels[composeProjectLabel] == project {
+			return name
+		}
+	}
+	return ""
+}
+
+// ComposeSummary is the /docker-compose.json response.
+type ComposeSummary struct {
+	SHA256   string               `json:"sha256"` // of the file as stored on disk
+	Services []ComposeServiceInfo `json:"services"`
+	Volumes  []string             `json:"volumes,omitempty"`  // named volumes
+	Networks []string             `json:"networks,omitempty"` // named networks
+}
+
+// ComposeServiceInfo summarizes a compose service. Environment values are
+// never included, only their names.
+type ComposeServiceInfo struct {
+	Name          string   `json:"name"`
+	Image         string   `json:"image,omitempty"`
+	ContainerName string   `json:"container_name,omitempty"`
+	Build         bool     `json:"build,omitempty"` // built from a local context
+	Ports         []string `json:"ports,omitempty"`
+	Volumes       []string `json:"volumes,omitempty"`
+	EnvKeys       []string `json:"env_keys,omitempty"`
+	EnvFiles      []string `json:"env_files,omitempty"`
+}
+
+// summarizeCompose builds the JSON view of a compose file; the caller sets SHA256.
+func summarizeCompose(content []byte) (*ComposeSummary, error) {
+	cf, err := parseComposeFile(content)
+	if err != nil {
+		return nil, err
+	}
+	sum := &ComposeSummary{Services: []ComposeServiceInfo{}}
+	for name, svc := range cf.Services {
+		info := ComposeServiceInfo{
+			Name:          name,
+			Image:         svc.Image,
+			ContainerName: svc.ContainerName,
+			Build:         !svc.Build.IsZero(),
+			EnvKeys:       envKeys(&svc.Environment),
+			EnvFiles:      scalarList(&svc.EnvFile),
+		}
+		for i := range svc.Ports {
+			info.Ports = append(info.Ports, composePort(&svc.Ports[i]))
+		}
+		for i := range svc.Volumes {
+			info.Volumes = append(info.Volumes, composeVolume(&svc.Volumes[i]))
+		}
+		sum.Services = append(sum.Services, info)
+	}
+	sort.Slice(sum.Services, func(i, j int) bool { return sum.Services[i].Name < sum.Services[j].Name })
+	sum.Volumes = sortedKeys(cf.Volumes)
+	sum.Networks = sortedKeys(cf.Networks)
+	return sum, nil
+}
+
+func sortedKeys(m map[string]yaml.Node) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// envKeys returns the variable names of an environment section, which is
+// either a mapping or a list of "KEY=value" strings.
+func envKeys(n *yaml.Node) []string {
+	var keys []string
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			keys = append(keys, n.Content[i].Value)
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			k, _, _ := strings.Cut(item.Value, "=")
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scalarList returns a scalar or a list of scalars as a slice.
+func scalarList(n *yaml.Node) []string {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return []string{n.Value}
+	case yaml.SequenceNode:
+		var out []string
+		for _, item := range n.Content {
+			if item.Kind == yaml.ScalarNode {
+				out = append(out, item.Value)
+			} else if path := mappingValue(item, "path"); path != "" {
+				out = append(out, path)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// mappingValue returns the scalar value of key in a mapping node.
+func mappingValue(n *yaml.Node, key string) string {
+	if n.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// composePort renders a port in short syntax ("8080:80/tcp"); the long
+// syntax (target, published, protocol) is converted.
+func composePort(n *yaml.Node) string {
+	if n.Kind != yaml.MappingNode {
+		return n.Value
+	}
+	port := mappingValue(n, "target")
+	if published := mappingValue(n, "published"); published != "" {
+		port = published + ":" + port
+		if ip := mappingValue(n, "host_ip"); ip != "" {
+			port = ip + ":" + port
+		}
+	}
+	if proto := mappingValue(n, "protocol"); proto != "" {
+		port += "/" + proto
+	}
+	return port
+}
+
+// composeVolume renders a mount in short syntax ("source:target[:ro]"); the
+// long syntax (type, source, target, read_only) is converted.
+func composeVolume(n *yaml.Node) string {
+	if n.Kind != yaml.MappingNode {
+		return n.Value
+	}
+	vol := mappingValue(n, "target")
+	if source := mappingValue(n, "source"); source != "" {
+		vol = source + ":" + vol
+	}
+	if ro, _ := strconv.ParseBool(mappingValue(n, "read_only")); ro {
+		vol += ":ro"
+	}
+	return vol
+}
+
+// maskComposeSecrets returns the compose file with the values of
+// environment entries whose names look secret replaced by redactedMark,
+// and the number of values masked. Environment entries that come from
+// anchors (e.g. under an x- extension field) are masked where they are
+// anchored, so aliases and << merges reveal nothing either. Comments are
+// kept, but the file is re-encoded, so its formatting may differ from the
+// original.
+func maskComposeSecrets(content []byte) ([]byte, int, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, 0, err
+	}
+	if len(doc.Content) == 0 {
+		return content, 0, nil
+	}
+	services := mappingNode(doc.Content[0], "services")
+	if services == nil {
+		return content, 0, nil
+	}
+	masked := 0
+	seen := map[*yaml.Node]bool{}
+	for i := 1; i < len(services.Content); i += 2 {
+		for _, env := range serviceEnvironments(services.Content[i], seen) {
+			masked += maskEnvironment(env, seen)
+		}
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, 0, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), masked, nil
+}
+
+// serviceEnvironments returns the environment sections of a service,
+// including those of the mappings merged into it with <<. seen guards
+// against alias cycles.
+func serviceEnvironments(svc *yaml.Node, seen map[*yaml.Node]bool) []*yaml.Node {
+	svc = resolveAlias(svc)
+	if svc == nil || seen[svc] {
+		return nil
+	}
+	seen[svc] = true
+	var envs []*yaml.Node
+	if env := mappingNode(svc, "environment"); env != nil {
+		envs = append(envs, env)
+	}
+	for _, merged := range mergedNodes(svc) {
+		envs = append(envs, serviceEnvironments(merged, seen)...)
+	}
+	return envs
+}
+
+// maskEnvironment masks the secret values of an environment section and of
+// the mappings merged into it, and returns the number masked. A section
+// shared through an anchor is masked, and counted, once.
+func maskEnvironment(env *yaml.Node, seen map[*yaml.Node]bool) int {
+	env = resolveAlias(env)
+	if env == nil || seen[env] {
+		return 0
+	}
+	seen[env] = true
+	masked := 0
+	switch env.Kind {
+	case yaml.MappingNode:
+		for j := 0; j+1 < len(env.Content); j += 2 {
+			if env.Content[j].ShortTag() == mergeTag {
+				continue
+			}
+			if v := env.Content[j+1]; v.Kind == yaml.ScalarNode && secretEnvKeyRegex.MatchString(env.Content[j].Value) && v.Value != "" {
+				v.Value, v.Tag, v.Style = redactedMark, "!!str", 0
+				masked++
+			}
+		}
+		for _, merged := range mergedNodes(env) {
+			masked += maskEnvironment(merged, seen)
+		}
+	case yaml.SequenceNode:
+		for _, item := range env.Content {
+			if k, v, ok := strings.Cut(item.Value, "="); ok && v != "" && secretEnvKeyRegex.MatchString(k) {
+				item.Value = k + "=" + redactedMark
+				masked++
+			}
+		}
+	}
+	return masked
+}
+
+// mergeTag is the tag of the << key that merges mappings into a mapping.
+const mergeTag = "!!merge"
+
+// mergedNodes returns the mappings merged into a mapping node with <<,
+// which takes an alias or a list of aliases.
+func mergedNodes(n *yaml.Node) []*yaml.Node {
+	var merged []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].ShortTag() != mergeTag {
+			continue
+		}
+		v := n.Content[i+1]
+		if v.Kind == yaml.SequenceNode {
+			merged = append(merged, v.Content...)
+		} else {
+			merged = append(merged, v)
+		}
+	}
+	return merged
+}
+
+// resolveAlias returns the node an alias node refers to, or n itself.
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	if n != nil && n.Kind == yaml.AliasNode {
+		return n.Alias
+	}
+	return n
+}
+
+// mappingNode returns the node under key in a mapping node, following aliases.
+func mappingNode(n *yaml.Node, key string) *yaml.Node {
+	n = resolveAlias(n)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return resolveAlias(n.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// composeDigest returns the hex SHA-256 of the compose file content.
+func composeDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// readComposeFile reads the compose file for a response, masking secret
+// environment values when the request has ?mask=true. The digest always
+// covers the unmasked file. It writes the error response itself and returns
+// ok=false on failure.
+func readComposeFile(w http.ResponseWriter, r *http.Request) (content []byte, digest string, ok bool) {
+	path := DockerComposePath
+	if path == "" {
+		respondWithError(w, http.StatusInternalServerError,
+			"Configuration error", "SECRETVM_DOCKER_COMPOSE_PATH is not set")
+		return nil, "", false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound,
+			"File not found", fmt.Sprintf("Could not read file %s: %v", path, err))
+		return nil, "", false
+	}
+	digest = composeDigest(content)
+	if mask, _ := strconv.ParseBool(r.URL.Query().Get("mask")); mask {
+		if content, _, err = maskComposeSecrets(content); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Invalid compose file", err.Error())
+			return nil, "", false
+		}
+	}
+	w.Header().Set(ComposeDigestHeader, digest)
+	return content, digest, true
+}
+
+// MakeDockerComposeJSONHandler implements /docker-compose.json: the services
+// of the compose file with their images, ports, volumes and environment
+// variable names.
+func MakeDockerComposeJSONHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+		content, digest, ok := readComposeFile(w, r)
+		if !ok {
+			return
+		}
+		sum, err := summarizeCompose(content)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Invalid compose file", err.Error())
+			return
+		}
+		sum.SHA256 = digest
+		respondWithJSON(w, http.StatusOK, sum)
+	}
+}
@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testCompose = `# workload
+services:
+  web:
+    image: ghcr.io/acme/web:1.2
+    ports:
+      - "443:8080"
+      - target: 9090
+        published: 9091
+        protocol: udp
+    volumes:
+      - data:/var/lib/web:ro
+      - type: bind
+        source: /mnt/secure/certs
+        target: /certs
+        read_only: true
+    environment:
+      DB_PASSWORD: hunter2
+      LOG_LEVEL: debug
+  worker:
+    build: ./worker
+    env_file: .env
+    environment:
+      - API_KEY=abc123
+      - MODE=batch
+volumes:
+  data: {}
+`
+
+func serveCompose(t *testing.T, h http.HandlerFunc, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	withComposeFile(t, testCompose)
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/docker-compose"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	return rec
+}
+
+func TestDockerComposeRawAndMasked(t *testing.T) {
+	digest := composeDigest([]byte(testCompose))
+
+	rec := serveCompose(t, MakeDockerComposeFileHandler(), "")
+	if rec.Body.String() != testCompose || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/yaml") {
+		t.Errorf("raw response: %q %q", rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if rec.Header().Get(ComposeDigestHeader) != digest {
+		t.Errorf("digest header = %q", rec.Header().Get(ComposeDigestHeader))
+	}
+
+	rec = serveCompose(t, MakeDockerComposeFileHandler(), "?mask=true")
+	body := rec.Body.String()
+	for _, secret := range []string{"hunter2", "abc123"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("masked file contains %q:\n%s", secret, body)
+		}
+	}
+	for _, kept := range []string{"LOG_LEVEL: debug", "MODE=batch", "API_KEY=" + redactedMark, "# workload"} {
+		if !strings.Contains(body, kept) {
+			t.Errorf("masked file lacks %q:\n%s", kept, body)
+		}
+	}
+	if rec.Header().Get(ComposeDigestHeader) != digest {
+		t.Error("masked response does not carry the digest of the unmasked file")
+	}
+}
+
+func TestMaskComposeSecretsAnchors(t *testing.T) {
+	const compose = `x-env: &env
+  API_KEY: abc123
+  REGION: eu
+x-common: &common
+  environment:
+    SESSION_SECRET: s3ss10n
+services:
+  web:
+    <<: *common
+    image: web
+  worker:
+    image: worker
+    environment:
+      <<: *env
+      DB_PASSWORD: hunter2
+  cron:
+    image: cron
+    environment: *env
+`
+	out, masked, err := maskComposeSecrets([]byte(compose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(out)
+	for _, secret := range []string{"abc123", "s3ss10n", "hunter2"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("masked file contains %q:\n%s", secret, body)
+		}
+	}
+	for _, kept := range []string{"REGION: eu", "<<: *env", "<<: *common", "environment: *env"} {
+		if !strings.Contains(body, kept) {
+			t.Errorf("masked file lacks %q:\n%s", kept, body)
+		}
+	}
+	// The shared anchor is masked once.
+	if masked != 3 {
+		t.Errorf("masked %d values, want 3", masked)
+	}
+}
+
+func TestDockerComposeJSON(t *testing.T) {
+	rec := serveCompose(t, MakeDockerComposeJSONHandler(), ".json")
+	var sum ComposeSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &sum); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Error("JSON view contains an environment value")
+	}
+	if len(sum.Services) != 2 || sum.SHA256 != composeDigest([]byte(testCompose)) || strings.Join(sum.Volumes, ",") != "data" {
+		t.Fatalf("summary = %+v", sum)
+	}
+	web, worker := sum.Services[0], sum.Services[1]
+	if strings.Join(web.Ports, ",") != "443:8080,9091:9090/udp" ||
+		strings.Join(web.Volumes, ",") != "data:/var/lib/web:ro,/mnt/secure/certs:/certs:ro" ||
+		strings.Join(web.EnvKeys, ",") != "DB_PASSWORD,LOG_LEVEL" {
+		t.Errorf("web = %+v", web)
+	}
+	if !worker.Build || strings.Join(worker.EnvKeys, ",") != "API_KEY,MODE" || strings.Join(worker.EnvFiles, ",") != ".env" {
+		t.Errorf("worker = %+v", worker)
+	}
+}
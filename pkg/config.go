@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -110,6 +112,13 @@ func init() {
 	// Certificate paths
 	CertPath = GetEnv("SECRETVM_CERT_PATH", "cert/ssl_cert.pem")
 	KeyPath = GetEnv("SECRETVM_KEY_PATH", "cert/ssl_key.pem")
+	CertReloadInterval = time.Duration(GetInt("SECRETVM_CERT_RELOAD_INTERVAL_SEC", 30)) * time.Second
+
+	// Remote-attested TLS
+	RATLSMode = GetEnv("SECRETVM_RATLS_MODE", "off")
+	RATLSServerName = GetEnv("SECRETVM_RATLS_SNI", "ratls.secretvm")
+	RATLSValidity = time.Duration(GetInt("SECRETVM_RATLS_VALIDITY_DAYS", 30)) * 24 * time.Hour
+	TSMReportPath = GetEnv("SECRETVM_TSM_REPORT_PATH", "/sys/kernel/config/tsm/report")
 
 	// Attestation tool configuration
 	AttestTool = GetEnv("SECRETVM_ATTEST_TOOL", "attest_tool")
@@ -123,16 +132,47 @@ func init() {
 	// Path to docker-compose file (must be set in env).
 	DockerComposePath = GetEnv("SECRETVM_DOCKER_COMPOSE_PATH", "docker_compose.yaml")
 
+	// systemd units whose journal is exposed by /logs
+	LogUnits = GetList("SECRETVM_LOG_UNITS", []string{"secret-vm-supervisor", "secret-vm-attest-rest"})
+
+	// Docker Engine API socket used for container listing, logs and inspection
+	DockerSocket = GetEnv("SECRETVM_DOCKER_SOCKET", "/var/run/docker.sock")
+
+	// Background resource sampling for /resources and /resources/history
+	ResourceSampleInterval = time.Duration(GetInt("SECRETVM_RESOURCE_SAMPLE_INTERVAL_SEC", 5)) * time.Second
+	ResourceHistoryRetention = time.Duration(GetInt("SECRETVM_RESOURCE_HISTORY_HOURS", 24)) * time.Hour
+
+	// cgroup v2 hierarchy and proc filesystem read for per-container resources
+	CgroupRoot = GetEnv("SECRETVM_CGROUP_ROOT", "/sys/fs/cgroup")
+	ProcRoot = GetEnv("SECRETVM_PROC_ROOT", "/proc")
+
+	// Log-based alert rules and the webhook notified when they fire or resolve
+	AlertWebhookURL = GetEnv("SECRETVM_ALERT_WEBHOOK_URL", "")
+	AlertWebhookRetries = GetInt("SECRETVM_ALERT_WEBHOOK_RETRIES", 3)
+
 	// Path to vm config file (must be set in env).
 	VmConfigPath = GetEnv("SECRETVM_CONFIG_PATH", "/mnt/config/secret-vm.json")
 
+	// Orchestrator status file read by /status and /readyz
+	StatusPath = GetEnv("SECRETVM_STATUS_PATH", "/var/run/svm_status")
+
 	// Path to filesystem mount point
 	FsMountPath = GetEnv("SECRETVM_FS_MOUNT_PATH", "/mnt/secure")
 
+	// Filesystems reported by /resources, besides the detected Docker data root
+	DiskMounts = GetList("SECRETVM_DISK_MOUNTS", []string{FsMountPath, filepath.Dir(VmConfigPath)})
+	DiskWarnPercent = GetInt("SECRETVM_DISK_WARN_PERCENT", 85)
+	DiskCriticalPercent = GetInt("SECRETVM_DISK_CRITICAL_PERCENT", 95)
+
+	// Container lifecycle and health event history served by /services/events
+	EventsPath = GetEnv("SECRETVM_EVENTS_PATH", filepath.Join(FsMountPath, "container_events.ndjson"))
+	EventsMax = GetInt("SECRETVM_EVENTS_MAX", 1000)
+
 	SystemInfoPath = GetEnv("SECRETVM_SYSTEM_INFO_PATH", "/mnt/secure/system_info.json")
 
 	PublicKeyEd25519Path = GetEnv("SECRETVM_PUBLIC_KEY_ED25519", "/mnt/secure/docker_wd/crypto/docker_public_key_ed25519.pem")
 	PublicKeySecp256k1Path = GetEnv("SECRETVM_PUBLIC_KEY_SECP256K1", "/mnt/secure/docker_wd/crypto/docker_public_key_secp256k1.pem")
+	PrivateKeyEd25519Path = GetEnv("SECRETVM_PRIVATE_KEY_ED25519", "/mnt/secure/docker_wd/crypto/docker_private_key_ed25519.pem")
 
 	// New sensitive config from extra env
 	AccessToken = GetEnv("SECRETVM_DEV_TOKEN", "")             // header: X-Dev-Token
@@ -148,8 +188,34 @@ func init() {
 		}
 	}
 
+	// Persistent log history served by /logs?history=true (empty dir disables it)
+	LogStoreDir = GetEnv("SECRETVM_LOG_STORE_DIR", filepath.Join(FsMountPath, "logstore"))
+	LogStoreMaxSize = int64(GetInt("SECRETVM_LOG_STORE_MAX_MB", 256)) << 20
+	LogStoreSegmentSize = int64(GetInt("SECRETVM_LOG_STORE_SEGMENT_MB", 16)) << 20
+
+	// Owner-only container restart, stop and start (off by default)
+	EnableContainerControl = GetBool("SECRETVM_ENABLE_CONTAINER_CONTROL", false)
+	TamperingPath = GetEnv("SECRETVM_TAMPERING_PATH", filepath.Join(FsMountPath, "tampering.json"))
+
+	// Audit trail and brute-force protection for guarded endpoints
+	AuditLogPath = GetEnv("SECRETVM_AUDIT_LOG_PATH", filepath.Join(FsMountPath, "audit.log"))
+	AuditLogMaxSize = int64(GetInt("SECRETVM_AUDIT_LOG_MAX_MB", 10)) << 20
+	AuditLogFiles = GetInt("SECRETVM_AUDIT_LOG_FILES", 3)
+	AuthMaxFailures = GetInt("SECRETVM_AUTH_MAX_FAILURES", 5)
+	AuthLockoutBase = time.Duration(GetInt("SECRETVM_AUTH_LOCKOUT_BASE_SEC", 30)) * time.Second
+	AuthLockoutMax = time.Duration(GetInt("SECRETVM_AUTH_LOCKOUT_MAX_SEC", 3600)) * time.Second
+
 	loadSystemInfo()
 
+	Audit = NewAuditLog(AuditLogPath, 1000, AuditLogMaxSize, AuditLogFiles)
+	Lockout = NewLockoutTracker(AuthMaxFailures, AuthLockoutBase, AuthLockoutMax)
+	Docker = NewDockerClient(DockerSocket)
+	LogRedactor = newLogRedactor(GetEnv("SECRETVM_LOG_REDACT_PATTERNS", ""))
+	ContainerEvents = NewEventHistory(EventsPath, EventsMax)
+	Tampering = NewTamperingCounter(TamperingPath)
+	Resources = NewResourceSampler(ResourceSampleInterval, ResourceHistoryRetention)
+	Alerts = newAlertEngine(GetEnv("SECRETVM_ALERT_RULES", ""), AlertWebhookURL, AlertWebhookRetries)
+
 	// Create report directory if it doesn't exist
 	if err := os.MkdirAll(ReportDir, 0755); err != nil {
 		log.Printf("Warning: Failed to create report directory %s: %v", ReportDir, err)
@@ -186,6 +252,21 @@ func GetInt(key string, fallback int) int {
 	return fallback
 }
 
+// GetList returns the comma-separated values of an environment variable if set; otherwise returns the fallback value.
+func GetList(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 // Global configuration variables.
 var (
 	// Server configuration
@@ -197,6 +278,14 @@ var (
 	KeyPath      string // Path to SSL key file
 	PrivateMode  bool   // to show or hide logs and docker_compose.yaml
 
+	CertReloadInterval time.Duration // How often the certificate files are checked for changes
+
+	// Remote-attested TLS
+	RATLSMode       string        // off, alongside or only
+	RATLSServerName string        // SNI selecting the RA-TLS certificate in alongside mode
+	RATLSValidity   time.Duration // Validity of the self-issued RA-TLS certificate
+	TSMReportPath   string        // configfs-tsm report directory used to obtain quotes
+
 	// Attestation tool configuration
 	AttestTool    string        // Command name for the attestation tool
 	AttestTimeout time.Duration // Timeout for attestation command execution
@@ -210,13 +299,51 @@ var (
 	DockerComposePath string
 	VmConfigPath      string
 
+	LogUnits    []string  // systemd units included in the logs
+	LogRedactor *Redactor // Removes secrets from exposed log lines
+
+	DockerSocket string        // Unix socket of the Docker Engine API
+	Docker       *DockerClient // Client for DockerSocket
+
+	ResourceSampleInterval   time.Duration    // Time between resource samples
+	ResourceHistoryRetention time.Duration    // How long samples are kept for /resources/history
+	Resources                *ResourceSampler // Started by main
+
+	CgroupRoot string // cgroup v2 mount holding the container cgroups
+	ProcRoot   string // proc filesystem, for the containers' network counters
+
+	EnableContainerControl bool              // Serve POST /services/{name}/{action}
+	TamperingPath          string            // JSON file persisting the tampering counter
+	Tampering              *TamperingCounter // Counts container control actions, shown on /status
+
+	EventsPath      string        // JSON-lines file keeping the container event history
+	EventsMax       int           // Events kept in memory; the file is compacted at twice as many
+	ContainerEvents *EventHistory // Fed by the Docker event watcher started in main
+
+	LogStoreDir         string    // Directory of the persistent log store
+	LogStoreMaxSize     int64     // Bytes kept before the oldest segments are deleted
+	LogStoreSegmentSize int64     // Bytes per segment file
+	LogHistory          *LogStore // Set by main when the collector runs
+
+	AlertWebhookURL     string       // Receives alert notifications as JSON POSTs (empty disables them)
+	AlertWebhookRetries int          // Retries of a failed webhook delivery
+	Alerts              *AlertEngine // Evaluates SECRETVM_ALERT_RULES on collected logs
+
+	// Orchestrator status file
+	StatusPath string
+
 	// Filesystem mount path
 	FsMountPath string
 
+	DiskMounts          []string // Paths whose filesystems /resources reports
+	DiskWarnPercent     int      // Disk or inode usage raising a warning
+	DiskCriticalPercent int      // Disk or inode usage raising a critical warning
+
 	SystemInfoPath string // Path to system_info.json
 
 	PublicKeyEd25519Path   string
 	PublicKeySecp256k1Path string
+	PrivateKeyEd25519Path  string // Signs /logs responses on ?sign=true
 
 	// Cached values from system_info.json or VM config
 	EnvValue       string
@@ -229,4 +356,14 @@ var (
 	ItaKeys   map[string]ItaKeyInfo
 	EnableItaJwt bool
 	EnablePocJwt bool
+
+	// Access auditing and lockout
+	AuditLogPath    string        // JSON-lines file recording guarded endpoint access
+	AuditLogMaxSize int64         // Size at which the audit file is rotated
+	AuditLogFiles   int           // Rotated audit files kept
+	AuthMaxFailures int           // Failed token attempts before a client is locked out
+	AuthLockoutBase time.Duration // First lockout duration, doubled on each further failure
+	AuthLockoutMax  time.Duration // Upper bound for the lockout duration
+	Audit           *AuditLog
+	Lockout         *LockoutTracker
 )
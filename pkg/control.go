@@ -0,0 +1,218 @@
+// pkg/control.go
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// containerActions are the actions accepted by /services/{name}/{action}.
+var containerActions = []string{"restart", "stop", "start"}
+
+// TamperingAction describes the latest container control action.
+type TamperingAction struct {
+	Time      time.Time `json:"time"`
+	Container string    `json:"container"`
+	Action    string    `json:"action"`
+	Client    string    `json:"client"`
+}
+
+// TamperingState is shown on /status: how often the workload's containers were
+// started, stopped or restarted through the API since the VM was provisioned.
+type TamperingState struct {
+	Count int              `json:"count"`
+	Last  *TamperingAction `json:"last,omitempty"`
+	Error string           `json:"error,omitempty"` // set when the stored count could not be read
+}
+
+// TamperingCounter persists TamperingState as a JSON file, so the count
+// survives restarts of the server.
+type TamperingCounter struct {
+	mu      sync.Mutex
+	path    string
+	state   TamperingState
+	loadErr error
+}
+
+// NewTamperingCounter creates a counter backed by path, loading the count
+// already stored there. A file that exists but cannot be read or parsed
+// leaves the counter failed: control actions are refused rather than
+// counted from zero again.
+func NewTamperingCounter(path string) *TamperingCounter {
+	t := &TamperingCounter{path: path}
+	if path == "" {
+		return t
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			t.loadErr = err
+		}
+	} else if err := json.Unmarshal(data, &t.state); err != nil {
+		t.state = TamperingState{}
+		t.loadErr = fmt.Errorf("invalid %s: %v", path, err)
+	}
+	if t.loadErr != nil {
+		log.Printf("Tampering: refusing control actions: %v", t.loadErr)
+	}
+	return t
+}
+
+// Record counts an action and writes the new state to disk. The action is
+// refused if the stored count could not be loaded or the new state cannot
+// be persisted, so no control action goes uncounted.
+func (t *TamperingCounter) Record(a TamperingAction) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loadErr != nil {
+		return 0, t.loadErr
+	}
+	next := TamperingState{Count: t.state.Count + 1, Last: &a}
+	if t.path != "" {
+		data, err := json.Marshal(next)
+		if err != nil {
+			return 0, err
+		}
+		if dir := filepath.Dir(t.path); dir != "" {
+			_ = os.MkdirAll(dir, 0700)
+		}
+		tmp := t.path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			return 0, err
+		}
+		if err := os.Rename(tmp, t.path); err != nil {
+			return 0, err
+		}
+	}
+	t.state = next
+	return next.Count, nil
+}
+
+// State returns the current count and latest action.
+func (t *TamperingCounter) State() TamperingState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.state
+	if t.loadErr != nil {
+		st.Error = t.loadErr.Error()
+	}
+	return st
+}
+
+// ContainerActionResult is the /services/{name}/{action} response.
+type ContainerActionResult struct {
+	Container      string `json:"container"`
+	Service        string `json:"service"` // compose service of the container
+	Action         string `json:"action"`
+	Changed        bool   `json:"changed"` // false if the container already was in the requested state
+	TamperingCount int    `json:"tampering_count"`
+}
+
+// composeContainer returns the container called name if it belongs to a
+// service of the compose file. It writes the error response itself and
+// returns ok=false otherwise.
+func composeContainer(w http.ResponseWriter, r *http.Request, name string) (c DockerContainer, service string, ok bool) {
+	if DockerComposePath == "" {
+		respondWithError(w, http.StatusInternalServerError,
+			"Configuration error", "SECRETVM_DOCKER_COMPOSE_PATH is not set")
+		return c, "", false
+	}
+	content, err := os.ReadFile(DockerComposePath)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError,
+			"Compose file unavailable", fmt.Sprintf("Could not read file %s: %v", DockerComposePath, err))
+		return c, "", false
+	}
+	cf, err := parseComposeFile(content)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Invalid compose file", err.Error())
+		return c, "", false
+	}
+	containers, err := Docker.ListContainers(r.Context(), true)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Docker unavailable", err.Error())
+		return c, "", false
+	}
+	for _, c := range containers {
+		if c.Name() != name {
+			continue
+		}
+		if service := cf.serviceOf(c); service != "" {
+			return c, service, true
+		}
+		respondWithError(w, http.StatusForbidden, "Container not managed",
+			fmt.Sprintf("Container %s is not part of the compose project", name))
+		return c, "", false
+	}
+	respondWithError(w, http.StatusNotFound, "Container not found", fmt.Sprintf("No container named %s", name))
+	return c, "", false
+}
+
+// MakeContainerControlHandler implements POST /services/{name}/{action}:
+// restart, stop or start a container of the compose project. Every action
+// is written to the audit log and increments the tampering counter on
+// /status before it is sent to Docker.
+func MakeContainerControlHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only POST requests are supported")
+			return
+		}
+		if !EnableContainerControl {
+			respondWithError(w, http.StatusNotFound, "Container control not enabled",
+				"Container control actions are not enabled for this VM")
+			return
+		}
+		name, action := r.PathValue("name"), r.PathValue("action")
+		if !slices.Contains(containerActions, action) {
+			respondWithError(w, http.StatusNotFound, "Unknown action",
+				fmt.Sprintf("Action %q is not one of %s", action, strings.Join(containerActions, ", ")))
+			return
+		}
+		c, service, ok := composeContainer(w, r, name)
+		if !ok {
+			return
+		}
+
+		entry := newAuditEntry(r)
+		entry.Identity = tokenIdentity(extractToken(r), true)
+		entry.Outcome = AuditOutcomeAction
+		defer func() { Audit.Record(entry) }()
+
+		count, err := Tampering.Record(TamperingAction{
+			Time: entry.Time, Container: name, Action: action, Client: entry.Client,
+		})
+		if err != nil {
+			entry.Detail = fmt.Sprintf("%s %s refused: %v", action, name, err)
+			respondWithError(w, http.StatusInternalServerError, "Tampering counter unavailable", err.Error())
+			return
+		}
+		log.Printf("Control: %s %s requested by %s", action, name, entry.Client)
+		// Docker may take up to dockerControlTimeout, longer than the server's
+		// WriteTimeout; once counted, the action is not cancelled by the client
+		// going away, so the audit entry records its real outcome.
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(dockerControlTimeout + 5*time.Second))
+		changed, err := Docker.ContainerAction(context.WithoutCancel(r.Context()), c.ID, action)
+		if err != nil {
+			entry.Detail = fmt.Sprintf("%s %s failed: %v", action, name, err)
+			respondWithError(w, http.StatusBadGateway, "Container action failed", err.Error())
+			return
+		}
+		entry.Detail = fmt.Sprintf("%s %s", action, name)
+		if !changed {
+			entry.Detail += " (no change)"
+		}
+		respondWithJSON(w, http.StatusOK, ContainerActionResult{
+			Container: name, Service: service, Action: action, Changed: changed, TamperingCount: count,
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContainerControl(t *testing.T) {
+	var actions []string
+	mux := fakeDockerMux(t)
+	mux.HandleFunc("POST /containers/{id}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		actions = append(actions, r.PathValue("action")+" "+r.PathValue("id"))
+		if r.PathValue("action") == "start" {
+			w.WriteHeader(http.StatusNotModified) // already running
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	startFakeDocker(t, mux)
+
+	withComposeFile(t, "services:\n  web:\n    image: ghcr.io/acme/web:1.2\n")
+	tampering := filepath.Join(t.TempDir(), "tampering.json")
+	prevEnabled, prevTampering, prevAudit := EnableContainerControl, Tampering, Audit
+	defer func() { EnableContainerControl, Tampering, Audit = prevEnabled, prevTampering, prevAudit }()
+	Tampering = NewTamperingCounter(tampering)
+	Audit = NewAuditLog("", 100, 0, 0)
+
+	reg := NewRegistry(DefaultRoutes(false)...)
+	srv := http.NewServeMux()
+	if err := reg.Mount(srv); err != nil {
+		t.Fatal(err)
+	}
+	post := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer "+AccessToken)
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+	prevToken := AccessToken
+	AccessToken = "secret"
+	defer func() { AccessToken = prevToken }()
+
+	if rec := post("/services/web/restart"); rec.Code != http.StatusNotFound {
+		t.Errorf("disabled: status %d", rec.Code)
+	}
+	EnableContainerControl = true
+	for path, want := range map[string]int{
+		"/services/web/kill":        http.StatusNotFound,
+		"/services/nope/restart":    http.StatusNotFound,
+		"/services/console/restart": http.StatusForbidden, // not in the compose file
+	} {
+		if rec := post(path); rec.Code != want {
+			t.Errorf("%s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+
+	rec := post("/.well-known/services/web/restart")
+	var res ContainerActionResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("restart: %d %s", rec.Code, rec.Body)
+	}
+	if res.Service != "web" || !res.Changed || res.TamperingCount != 1 {
+		t.Errorf("restart = %+v", res)
+	}
+	rec = post("/services/web/start")
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || res.Changed || res.TamperingCount != 2 {
+		t.Errorf("start: %d %s", rec.Code, rec.Body)
+	}
+	if len(actions) != 2 || actions[0] != "restart aaa" {
+		t.Errorf("docker saw %v", actions)
+	}
+	if got := Audit.Query(AuditQuery{Outcome: AuditOutcomeAction}); len(got) != 2 || got[0].Detail != "restart web" {
+		t.Errorf("audit = %+v", got)
+	}
+
+	// The count survives a restart and is shown on /status.
+	Tampering = NewTamperingCounter(tampering)
+	rec = httptest.NewRecorder()
+	StatusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var status struct {
+		Tampering TamperingState `json:"tampering"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || status.Tampering.Count != 2 ||
+		status.Tampering.Last == nil || status.Tampering.Last.Action != "start" {
+		t.Errorf("/status = %s", rec.Body)
+	}
+
+	// An unreadable counter refuses actions instead of starting from zero.
+	if err := os.WriteFile(tampering, []byte("{truncated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	Tampering = NewTamperingCounter(tampering)
+	if rec := post("/services/web/restart"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("corrupt counter: status %d", rec.Code)
+	}
+	if st := Tampering.State(); st.Error == "" || st.Count != 0 || len(actions) != 2 {
+		t.Errorf("corrupt counter: state %+v, docker saw %v", st, actions)
+	}
+
+	AccessToken = ""
+	if rec := post("/services/web/restart"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a configured token: status %d", rec.Code)
+	}
+}
+
+func TestContainerControlOutlivesWriteTimeout(t *testing.T) {
+	mux := fakeDockerMux(t)
+	mux.HandleFunc("POST /containers/{id}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond) // the container takes a while to stop
+		w.WriteHeader(http.StatusNoContent)
+	})
+	startFakeDocker(t, mux)
+
+	withComposeFile(t, "services:\n  web:\n    image: ghcr.io/acme/web:1.2\n")
+	prevEnabled, prevTampering, prevAudit, prevToken := EnableContainerControl, Tampering, Audit, AccessToken
+	defer func() {
+		EnableContainerControl, Tampering, Audit, AccessToken = prevEnabled, prevTampering, prevAudit, prevToken
+	}()
+	EnableContainerControl = true
+	Tampering = NewTamperingCounter(filepath.Join(t.TempDir(), "tampering.json"))
+	Audit = NewAuditLog("", 100, 0, 0)
+	AccessToken = "secret"
+
+	reg := NewRegistry(DefaultRoutes(false)...)
+	srv := http.NewServeMux()
+	if err := reg.Mount(srv); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(srv)
+	ts.Config.WriteTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/services/web/stop", nil)
+	req.Header.Set("Authorization", "Bearer "+AccessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("response lost after the write timeout: %v", err)
+	}
+	defer resp.Body.Close()
+	var res ContainerActionResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil || resp.StatusCode != http.StatusOK || !res.Changed {
+		t.Errorf("stop: %d %+v (%v)", resp.StatusCode, res, err)
+	}
+}
@@ -0,0 +1,174 @@
+// pkg/disks.go
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// Levels of a ResourceWarning.
+const (
+	WarningLevelWarning  = "warning"
+	WarningLevelCritical = "critical"
+)
+
+// Kinds of a ResourceWarning: what about the filesystem it flags.
+const (
+	WarningKindSpace      = "space"
+	WarningKindInodes     = "inodes"
+	WarningKindUnreadable = "unreadable"
+	WarningKindMountpoint = "mountpoint"
+)
+
+// DiskStats is the usage of one filesystem in /resources.
+type DiskStats struct {
+	Path          string  `json:"path"`
+	Source        string  `json:"source"` // "configured", or "docker" for the detected Docker data root
+	Mountpoint    string  `json:"mountpoint,omitempty"`
+	Device        string  `json:"device,omitempty"`
+	FSType        string  `json:"fstype,omitempty"`
+	UsedGB        float64 `json:"used_gb"`
+	TotalGB       float64 `json:"total_gb"`
+	Percent       float64 `json:"percent"`
+	InodesUsed    uint64  `json:"inodes_used"`
+	InodesTotal   uint64  `json:"inodes_total"`
+	InodesPercent float64 `json:"inodes_percent"`
+	ReadOnly      bool    `json:"read_only"`
+	NotMountpoint bool    `json:"not_mountpoint,omitempty"` // a configured path inside another filesystem
+	Error         string  `json:"error,omitempty"`          // set when the path could not be read
+
+	used, total uint64 // bytes behind UsedGB and TotalGB, for /metrics
+}
+
+// ResourceWarning flags a filesystem that is filling up or unreadable.
+type ResourceWarning struct {
+	Level   string `json:"level"` // warning or critical
+	Kind    string `json:"kind"`  // space, inodes, unreadable or mountpoint
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// sampleDisks reads the usage of every path in paths and of dockerRoot, if
+// set and not on the same filesystem as one of them. The mount table is read
+// once per sample, to find each path's mountpoint and read-only state. A
+// path that does not exist is reported with an error rather than as the
+// filesystem of its parent, and a configured path that is not a mountpoint
+// itself is flagged, since its data then lands on the enclosing filesystem.
+func sampleDisks(paths []string, dockerRoot string) []DiskStats {
+	partitions, _ := disk.Partitions(true)
+	var out []DiskStats
+	seen := make(map[string]bool) // mountpoints already reported
+	add := func(path, source string) {
+		d := DiskStats{Path: path, Source: source}
+		if _, err := os.Stat(path); err != nil {
+			d.Error = err.Error()
+			out = append(out, d)
+			return
+		}
+		if p := mountOf(partitions, path); p != nil {
+			if seen[p.Mountpoint] {
+				return
+			}
+			seen[p.Mountpoint] = true
+			d.Mountpoint, d.Device, d.FSType = p.Mountpoint, p.Device, p.Fstype
+			d.NotMountpoint = source == "configured" && p.Mountpoint != filepath.Clean(path)
+			for _, opt := range p.Opts {
+				if opt == "ro" {
+					d.ReadOnly = true
+				}
+			}
+		}
+		u, err := disk.Usage(path)
+		if err != nil {
+			d.Error = err.Error()
+			out = append(out, d)
+			return
+		}
+		if d.FSType == "" {
+			d.FSType = u.Fstype
+		}
+		d.used, d.total = u.Used, u.Total
+		d.UsedGB, d.TotalGB, d.Percent = toGB(u.Used), toGB(u.Total), round2(u.UsedPercent)
+		d.InodesUsed, d.InodesTotal, d.InodesPercent = u.InodesUsed, u.InodesTotal, round2(u.InodesUsedPercent)
+		out = append(out, d)
+	}
+	for _, p := range paths {
+		add(p, "configured")
+	}
+	if dockerRoot != "" {
+		add(dockerRoot, "docker")
+	}
+	return out
+}
+
+// mountOf returns the partition holding path: the one with the longest
+// mountpoint that is a prefix of it.
+func mountOf(partitions []disk.PartitionStat, path string) *disk.PartitionStat {
+	path = filepath.Clean(path)
+	var best *disk.PartitionStat
+	for i := range partitions {
+		mp := partitions[i].Mountpoint
+		if path != mp && !strings.HasPrefix(path, strings.TrimSuffix(mp, "/")+"/") {
+			continue
+		}
+		// Later entries of the mount table shadow earlier ones on the same mountpoint.
+		if best == nil || len(mp) >= len(best.Mountpoint) {
+			best = &partitions[i]
+		}
+	}
+	return best
+}
+
+// diskWarnings flags disks whose space or inode usage reaches warnPct or
+// critPct, disks that could not be read and configured paths that are not
+// mountpoints. Read-only filesystems, such as
+// a config image, are full by design and are not checked.
+func diskWarnings(disks []DiskStats, warnPct, critPct float64) []ResourceWarning {
+	var out []ResourceWarning
+	level := func(pct float64) string {
+		switch {
+		case critPct > 0 && pct >= critPct:
+			return WarningLevelCritical
+		case warnPct > 0 && pct >= warnPct:
+			return WarningLevelWarning
+		}
+		return ""
+	}
+	for _, d := range disks {
+		if d.Error != "" {
+			out = append(out, ResourceWarning{Level: WarningLevelCritical, Kind: WarningKindUnreadable, Path: d.Path,
+				Message: "cannot read filesystem: " + d.Error})
+			continue
+		}
+		if d.NotMountpoint {
+			out = append(out, ResourceWarning{Level: WarningLevelWarning, Kind: WarningKindMountpoint, Path: d.Path,
+				Message: "not a mountpoint; on the filesystem mounted at " + d.Mountpoint})
+		}
+		if d.ReadOnly {
+			continue
+		}
+		if l := level(d.Percent); l != "" {
+			out = append(out, ResourceWarning{Level: l, Kind: WarningKindSpace, Path: d.Path,
+				Message: fmt.Sprintf("disk %.1f%% full (%.3f of %.3f GB)", d.Percent, d.UsedGB, d.TotalGB)})
+		}
+		if l := level(d.InodesPercent); l != "" {
+			out = append(out, ResourceWarning{Level: l, Kind: WarningKindInodes, Path: d.Path,
+				Message: fmt.Sprintf("%.1f%% of inodes in use (%d of %d)", d.InodesPercent, d.InodesUsed, d.InodesTotal)})
+		}
+	}
+	return out
+}
+
+// dockerRootDir asks the Docker daemon for its data root ("DockerRootDir").
+func dockerRootDir(ctx context.Context) (string, error) {
+	info, err := Docker.Info(ctx)
+	if err != nil {
+		return "", err
+	}
+	return info.DockerRootDir, nil
+}
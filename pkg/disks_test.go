@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func TestSampleDisks(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	disks := sampleDisks([]string{dir, missing}, "")
+	if len(disks) == 0 || disks[0].Path != dir || disks[0].Source != "configured" || disks[0].Error != "" {
+		t.Fatalf("disks = %+v", disks)
+	}
+	if disks[0].TotalGB <= 0 {
+		t.Errorf("no size for %s: %+v", dir, disks[0])
+	}
+	// A temp dir is never a mountpoint itself.
+	if disks[0].Mountpoint != "" && !disks[0].NotMountpoint {
+		t.Errorf("%s not flagged as inside %s", dir, disks[0].Mountpoint)
+	}
+	// The missing path is reported even though its parent's filesystem is.
+	if len(disks) != 2 || disks[1].Path != missing || disks[1].Error == "" || disks[1].Mountpoint != "" {
+		t.Errorf("missing path: %+v", disks[1:])
+	}
+}
+
+func TestMountOf(t *testing.T) {
+	partitions := []disk.PartitionStat{
+		{Mountpoint: "/", Device: "root"},
+		{Mountpoint: "/mnt/secure", Device: "old"},
+		{Mountpoint: "/mnt/secure", Device: "secure", Opts: []string{"ro"}},
+		{Mountpoint: "/mnt/sec", Device: "sec"},
+	}
+	for path, want := range map[string]string{
+		"/mnt/secure/docker": "secure",
+		"/mnt/secure":        "secure",
+		"/mnt/securefs":      "root",
+		"/var/lib/docker":    "root",
+	} {
+		if got := mountOf(partitions, path); got == nil || got.Device != want {
+			t.Errorf("mountOf(%s) = %+v, want %s", path, got, want)
+		}
+	}
+}
+
+func TestDiskWarnings(t *testing.T) {
+	warnings := diskWarnings([]DiskStats{
+		{Path: "/ok", Percent: 50, InodesPercent: 10},
+		{Path: "/full", Percent: 96, InodesPercent: 90},
+		{Path: "/ro", Percent: 100, ReadOnly: true},
+		{Path: "/gone", Error: "no such file or directory"},
+		{Path: "/mnt/data", Mountpoint: "/", NotMountpoint: true},
+	}, 85, 95)
+	got := make([]string, len(warnings))
+	for i, w := range warnings {
+		got[i] = w.Level + " " + w.Kind + " " + w.Path
+	}
+	want := "critical space /full,warning inodes /full,critical unreadable /gone,warning mountpoint /mnt/data"
+	if strings.Join(got, ",") != want {
+		t.Errorf("warnings = %v, want %s", got, want)
+	}
+}
+
+func TestDockerRootDir(t *testing.T) {
+	mux := fakeDockerMux(t)
+	mux.HandleFunc("GET /info", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"DockerRootDir":"/mnt/secure/docker","Containers":2}`))
+	})
+	startFakeDocker(t, mux)
+	if root, err := dockerRootDir(context.Background()); err != nil || root != "/mnt/secure/docker" {
+		t.Errorf("root = %q, %v", root, err)
+	}
+}
@@ -0,0 +1,352 @@
+// pkg/docker.go
+package pkg
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dockerTimeout bounds non-streaming Docker API requests.
+const dockerTimeout = 10 * time.Second
+
+// dockerControlTimeout bounds start, stop and restart requests, which wait
+// for the container's stop timeout (10 s by default) before killing it.
+const dockerControlTimeout = time.Minute
+
+// DockerClient talks to the Docker Engine API over its unix socket, so
+// listing, inspecting and reading logs of containers needs no docker CLI.
+type DockerClient struct {
+	socket string
+	http   *http.Client
+}
+
+// NewDockerClient returns a client for the Docker Engine API listening on socket.
+func NewDockerClient(socket string) *DockerClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+		MaxIdleConns:    4,
+		IdleConnTimeout: 30 * time.Second,
+	}
+	return &DockerClient{socket: socket, http: &http.Client{Transport: transport}}
+}
+
+// DockerError is a non-2xx response from the Docker Engine API.
+type DockerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *DockerError) Error() string {
+	return fmt.Sprintf("docker API: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// IsDockerNotFound reports whether err is a 404 from the Docker Engine API,
+// e.g. for an unknown container name.
+func IsDockerNotFound(err error) bool {
+	var de *DockerError
+	return errors.As(err, &de) && de.StatusCode == http.StatusNotFound
+}
+
+// DockerContainer is an entry of GET /containers/json.
+type DockerContainer struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	ImageID string            `json:"ImageID"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Created int64             `json:"Created"`
+	Labels  map[string]string `json:"Labels"`
+	Ports   []DockerPort      `json:"Ports"`
+}
+
+// DockerPort is a port exposed by a container; PublicPort is 0 unless published.
+type DockerPort struct {
+	IP          string `json:"IP,omitempty"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort,omitempty"`
+	Type        string `json:"Type"`
+}
+
+// DockerImageInspect is the subset of GET /images/{name}/json we use.
+type DockerImageInspect struct {
+	ID          string   `json:"Id"`
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// Name returns the container's primary name without the leading slash.
+func (c DockerContainer) Name() string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// DockerContainerInspect is the subset of GET /containers/{id}/json we use.
+type DockerContainerInspect struct {
+	ID           string `json:"Id"`
+	Name         string `json:"Name"`
+	Image        string `json:"Image"`
+	RestartCount int    `json:"RestartCount"`
+	State        struct {
+		Status     string `json:"Status"`
+		Running    bool   `json:"Running"`
+		Pid        int    `json:"Pid"`
+		ExitCode   int    `json:"ExitCode"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+		Health     *struct {
+			Status string `json:"Status"`
+		} `json:"Health,omitempty"`
+	} `json:"State"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Tty    bool              `json:"Tty"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// DockerEvent is a message of GET /events.
+type DockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"` // e.g. "start", "die", "health_status: healthy"
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// DockerLogsOptions are the query options of GET /containers/{id}/logs.
+type DockerLogsOptions struct {
+	Follow bool
+	Tail   int // number of lines from the end; negative means all
+	Since  time.Time
+	Until  time.Time
+}
+
+// do sends a request to the Engine API and returns the response on success.
+// Non-2xx responses are turned into a *DockerError.
+func (c *DockerClient) do(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	u := "http://docker" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker API %s: %w", c.socket, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var body struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, &body) != nil || body.Message == "" {
+			body.Message = strings.TrimSpace(string(data))
+		}
+		return nil, &DockerError{StatusCode: resp.StatusCode, Message: body.Message}
+	}
+	return resp, nil
+}
+
+// getJSON performs a GET request and decodes the JSON response into v.
+func (c *DockerClient) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, dockerTimeout)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// ListContainers returns the containers known to the daemon; all includes stopped ones.
+func (c *DockerClient) ListContainers(ctx context.Context, all bool) ([]DockerContainer, error) {
+	q := url.Values{}
+	if all {
+		q.Set("all", "1")
+	}
+	var out []DockerContainer
+	if err := c.getJSON(ctx, "/containers/json", q, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InspectContainer returns the details of a container by name or ID.
+func (c *DockerClient) InspectContainer(ctx context.Context, container string) (*DockerContainerInspect, error) {
+	var out DockerContainerInspect
+	if err := c.getJSON(ctx, "/containers/"+url.PathEscape(container)+"/json", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ContainerEvents streams the container events matching actions, starting
+// with those since the given time, until ctx is cancelled. Docker keeps a
+// short backlog of past events, so reconnecting with the time of the last
+// event seen loses nothing.
+func (c *DockerClient) ContainerEvents(ctx context.Context, since time.Time, actions []string, fn func(DockerEvent)) error {
+	filters, err := json.Marshal(map[string][]string{"type": {"container"}, "event": actions})
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	q.Set("filters", string(filters))
+	if !since.IsZero() {
+		q.Set("since", dockerTimeParam(since))
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/events", q)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev DockerEvent
+		if err := dec.Decode(&ev); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		fn(ev)
+	}
+}
+
+// ContainerAction performs a lifecycle action ("start", "stop" or "restart")
+// on a container. It reports changed=false when Docker answers 304 because
+// the container already is in the requested state.
+func (c *DockerClient) ContainerAction(ctx context.Context, container, action string) (changed bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, dockerControlTimeout)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(container)+"/"+action, nil)
+	var de *DockerError
+	if errors.As(err, &de) && de.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// DockerInfo is the part of GET /info the server uses.
+type DockerInfo struct {
+	DockerRootDir string `json:"DockerRootDir"`
+}
+
+// Info returns system-wide information about the Docker daemon.
+func (c *DockerClient) Info(ctx context.Context) (*DockerInfo, error) {
+	var out DockerInfo
+	if err := c.getJSON(ctx, "/info", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// InspectImage returns the details of an image by reference or ID.
+func (c *DockerClient) InspectImage(ctx context.Context, image string) (*DockerImageInspect, error) {
+	var out DockerImageInspect
+	if err := c.getJSON(ctx, "/images/"+url.PathEscape(image)+"/json", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// InspectContainerRaw returns the complete inspect document of a container.
+func (c *DockerClient) InspectContainerRaw(ctx context.Context, container string) (json.RawMessage, error) {
+	var out json.RawMessage
+	if err := c.getJSON(ctx, "/containers/"+url.PathEscape(container)+"/json", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ContainerLogs opens the raw log stream of a container with timestamps on
+// both stdout and stderr. The stream is multiplexed unless the container
+// has a TTY; use DemuxDockerStream to split it. The caller closes it.
+func (c *DockerClient) ContainerLogs(ctx context.Context, container string, opts DockerLogsOptions) (io.ReadCloser, error) {
+	q := url.Values{}
+	q.Set("stdout", "1")
+	q.Set("stderr", "1")
+	q.Set("timestamps", "1")
+	if opts.Follow {
+		q.Set("follow", "1")
+	}
+	if opts.Tail >= 0 {
+		q.Set("tail", strconv.Itoa(opts.Tail))
+	} else {
+		q.Set("tail", "all")
+	}
+	if !opts.Since.IsZero() {
+		q.Set("since", dockerTimeParam(opts.Since))
+	}
+	if !opts.Until.IsZero() {
+		q.Set("until", dockerTimeParam(opts.Until))
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(container)+"/logs", q)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// dockerTimeParam formats t as the fractional Unix timestamp the API expects.
+func dockerTimeParam(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// DemuxDockerStream copies a multiplexed log stream to stdout and stderr.
+// Each frame starts with an 8-byte header: the stream type (0 stdin,
+// 1 stdout, 2 stderr), three zero bytes, and the big-endian payload size.
+// Streams of TTY containers are not multiplexed and go to stdout as is.
+func DemuxDockerStream(r io.Reader, tty bool, stdout, stderr io.Writer) error {
+	if tty {
+		_, err := io.Copy(stdout, r)
+		return err
+	}
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("docker log frame header: %w", err)
+		}
+		var w io.Writer
+		switch hdr[0] {
+		case 0, 1:
+			w = stdout
+		case 2:
+			w = stderr
+		default:
+			return fmt.Errorf("docker log frame: unknown stream type %d", hdr[0])
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return fmt.Errorf("docker log frame payload: %w", err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dockerFrame encodes payload as a multiplexed log stream frame.
+func dockerFrame(stream byte, payload string) []byte {
+	hdr := make([]byte, 8)
+	hdr[0] = stream
+	binary.BigEndian.PutUint32(hdr[4:], uint32(len(payload)))
+	return append(hdr, payload...)
+}
+
+// startFakeDocker serves handler over a unix socket and points Docker at it.
+func startFakeDocker(t *testing.T, handler http.Handler) {
+	t.Helper()
+	// Unix socket paths are limited to ~108 bytes, so avoid t.TempDir().
+	dir, err := os.MkdirTemp("", "dkr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(l)
+
+	prev := Docker
+	Docker = NewDockerClient(socket)
+	t.Cleanup(func() {
+		Docker = prev
+		srv.Close()
+		os.RemoveAll(dir)
+	})
+}
+
+// withComposeFile writes compose to a temporary app/docker-compose.yaml, so
+// its project is "app" like the fake Docker's containers, and points
+// DockerComposePath at it until the test ends.
+func withComposeFile(t *testing.T, compose string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app", "docker-compose.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(compose), 0600); err != nil {
+		t.Fatal(err)
+	}
+	prev := DockerComposePath
+	DockerComposePath = path
+	t.Cleanup(func() { DockerComposePath = prev })
+	return path
+}
+
+func fakeDockerMux(t *testing.T) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("all") != "1" {
+			t.Errorf("list without all=1: %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"Id": "aaa", "Names": []string{"/web"}, "State": "running", "Image": "ghcr.io/acme/web:1.2", "ImageID": "sha256:web",
+				"Ports":  []map[string]interface{}{{"IP": "0.0.0.0", "PrivatePort": 8080, "PublicPort": 443, "Type": "tcp"}},
+				"Labels": map[string]string{"com.docker.compose.project": "app", "com.docker.compose.service": "web"}},
+			{"Id": "bbb", "Names": []string{"/console"}, "State": "exited"},
+		})
+	})
+	mux.HandleFunc("GET /containers/{name}/json", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("name") {
+		case "web":
+			w.Write([]byte(`{"Id":"aaa","Name":"/web","RestartCount":2,"State":{"Status":"running","Running":true,"Pid":4242,"StartedAt":"2025-08-27T07:58:20.5Z","FinishedAt":"0001-01-01T00:00:00Z","Health":{"Status":"healthy"}},"Config":{"Image":"ghcr.io/acme/web:1.2","Tty":false,"Env":["DB_PASSWORD=hunter2","PATH=/bin"]}}`))
+		case "console":
+			w.Write([]byte(`{"Id":"bbb","Name":"/console","State":{"Status":"exited","Pid":0},"Config":{"Tty":true}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"No such container: ` + r.PathValue("name") + `"}`))
+		}
+	})
+	mux.HandleFunc("GET /images/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "sha256:web" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"Id":"sha256:web","RepoDigests":["acme/mirror@sha256:111","ghcr.io/acme/web@sha256:222"]}`))
+	})
+	mux.HandleFunc("GET /containers/{name}/logs", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("timestamps") != "1" || q.Get("stdout") != "1" || q.Get("stderr") != "1" {
+			t.Errorf("unexpected log query: %s", r.URL.RawQuery)
+		}
+		switch r.PathValue("name") {
+		case "web":
+			if tail := q.Get("tail"); tail != "10" && tail != "all" {
+				t.Errorf("tail = %q", tail)
+			}
+			var b bytes.Buffer
+			// A line split across two frames, and interleaved stderr output.
+			b.Write(dockerFrame(1, "2025-08-27T07:58:24.000000001Z listening on "))
+			b.Write(dockerFrame(2, "2025-08-27T07:58:25.000000000Z ERROR bad request\n"))
+			b.Write(dockerFrame(1, ":8080\n2025-08-27T07:58:26.000000000Z ready\n"))
+			w.Write(b.Bytes())
+		case "console":
+			w.Write([]byte("2025-08-27T07:58:24Z tty output\r\n"))
+		}
+	})
+	return mux
+}
+
+func TestDockerClientListAndInspect(t *testing.T) {
+	startFakeDocker(t, fakeDockerMux(t))
+
+	names, err := listAllDockerContainerNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "web,console" {
+		t.Errorf("names = %v", names)
+	}
+
+	_, err = fetchDockerLogsForContainer("missing", 10, "vm", LogFilter{})
+	if !IsDockerNotFound(err) {
+		t.Errorf("missing container: err = %v", err)
+	}
+}
+
+func TestFetchDockerLogsDemux(t *testing.T) {
+	startFakeDocker(t, fakeDockerMux(t))
+
+	entries, err := fetchDockerLogsForContainer("web", 10, "vm", LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]LogEntry{}
+	for _, e := range entries {
+		got[e.Message] = e
+		if e.PID != 4242 || e.Host != "vm" || e.Source != "web" {
+			t.Errorf("entry metadata: %+v", e)
+		}
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries: %+v", len(entries), entries)
+	}
+	if e := got["listening on :8080"]; e.Stream != "stdout" || !e.Timestamp.Equal(time.Date(2025, 8, 27, 7, 58, 24, 1, time.UTC)) {
+		t.Errorf("joined frame entry: %+v", e)
+	}
+	if e := got["ERROR bad request"]; e.Stream != "stderr" || e.Level != LevelError {
+		t.Errorf("stderr entry: %+v", e)
+	}
+	if _, ok := got["ready"]; !ok {
+		t.Error("missing last stdout line")
+	}
+
+	// TTY containers are not multiplexed.
+	entries, err = fetchDockerLogsForContainer("console", 10, "vm", LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Message != "tty output" || entries[0].Stream != "stdout" {
+		t.Errorf("tty entries: %+v", entries)
+	}
+}
+
+func TestDemuxDockerStreamRejectsUnknownStream(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if err := DemuxDockerStream(bytes.NewReader(dockerFrame(7, "x")), false, &out, &errOut); err == nil {
+		t.Error("unknown stream type accepted")
+	}
+	truncated := dockerFrame(1, "hello")[:10]
+	if err := DemuxDockerStream(bytes.NewReader(truncated), false, &out, &errOut); err == nil {
+		t.Error("truncated frame accepted")
+	}
+}
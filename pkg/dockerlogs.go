@@ -0,0 +1,282 @@
+// pkg/dockerlogs.go
+package pkg
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Docker `--timestamps` format: "2025-08-27T07:58:24.123456789Z"
+const dockerTS = "2006-01-02T15:04:05.999999999Z07:00"
+
+const (
+	// maxLogLineBytes bounds a buffered log line; longer lines are passed on
+	// in chunks and joined again by dockerLogParser.
+	maxLogLineBytes = 1024 * 1024
+	// maxLogEntryBytes bounds an entry including its continuation lines.
+	maxLogEntryBytes = 4 * 1024 * 1024
+	// continuationWindow is how far apart a continuation line may be logged
+	// from the entry it belongs to.
+	continuationWindow = time.Second
+)
+
+// continuationRegex matches lines that continue the previous entry, such as
+// indented stack frames, Java "at ..." / "Caused by:" lines, Python
+// tracebacks and Go panic goroutine headers.
+var continuationRegex = regexp.MustCompile(`^(?:\s|at \S|Caused by:|\.\.\. \d+ (?:more|common frames omitted)|Traceback \(most recent call last\):|goroutine \d+ \[)`)
+
+// traceLineRegex matches further lines of a stack trace that is already
+// being collected: Go frames ("main.main()", "created by ..."), and the
+// final exception line of Python and Java traces.
+var traceLineRegex = regexp.MustCompile(`^(?:[\w./*()\[\]-]+\(.*\)$|created by |[\w.$]+(?:Error|Exception)\b)`)
+
+// exceptionHeaderRegex matches the first line of a Java stack trace: a
+// qualified exception class name, optionally followed by its message. Such a
+// line continues the previous entry when stack frames follow it.
+var exceptionHeaderRegex = regexp.MustCompile(`^(?:[a-z_$][\w$]*\.)+[A-Z][\w$]*(?:Exception|Error|Throwable)(?::\s|$)`)
+
+// lineWriter calls fn for every complete line written to it. Frames of the
+// Docker log stream do not necessarily end on line boundaries. Lines longer
+// than maxLogLineBytes are passed in chunks with partial set on all but the last.
+type lineWriter struct {
+	buf []byte
+	fn  func(line string, partial bool) error
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		partial := false
+		if i < 0 {
+			if len(lw.buf) < maxLogLineBytes {
+				return len(p), nil
+			}
+			i, partial = maxLogLineBytes, true
+		}
+		line := string(lw.buf[:i])
+		if !partial {
+			line = strings.TrimSuffix(line, "\r")
+			i++
+		}
+		lw.buf = lw.buf[i:]
+		if err := lw.fn(line, partial); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush passes a trailing line without newline to fn.
+func (lw *lineWriter) Flush() error {
+	if len(lw.buf) == 0 {
+		return nil
+	}
+	line := string(lw.buf)
+	lw.buf = nil
+	return lw.fn(line, false)
+}
+
+// parseDockerLogLine parses a `docker logs --timestamps` line into an entry.
+// The message keeps its indentation so continuation lines can be recognized.
+func parseDockerLogLine(l, container, stream string, pid int, hostName string) (LogEntry, bool) {
+	space := strings.IndexByte(l, ' ')
+	if space <= 0 {
+		return LogEntry{}, false
+	}
+	tsRaw := l[:space]
+	msg := strings.TrimRight(l[space+1:], " \t\r")
+
+	ts, err := time.Parse(dockerTS, tsRaw)
+	if err != nil {
+		if ts2, err2 := time.Parse(time.RFC3339, tsRaw); err2 == nil {
+			ts = ts2
+		} else {
+			return LogEntry{}, false
+		}
+	}
+	return LogEntry{
+		Timestamp: ts,
+		Host:      hostName,
+		Source:    container,
+		Stream:    stream,
+		PID:       pid,
+		Level:     detectLevel(msg),
+		Message:   msg,
+	}, true
+}
+
+// dockerLogParser assembles the lines of one container stream into entries.
+// An entry is held until the next one starts, so that continuation lines,
+// lines without a timestamp and the chunks of overlong lines end up in the
+// message of the entry they belong to.
+type dockerLogParser struct {
+	container, stream, hostName string
+	pid                         int
+	emit                        func(LogEntry) error
+
+	pending   *LogEntry
+	partial   bool      // pending ends with an incomplete line
+	inTrace   bool      // pending has continuation lines
+	held      *LogEntry // exception header that joins pending if stack frames follow
+	truncated bool      // pending reached maxLogEntryBytes
+	updated   time.Time // when pending last changed
+}
+
+// appendPending adds text to the pending entry, up to maxLogEntryBytes.
+func (p *dockerLogParser) appendPending(text string) {
+	p.updated = time.Now()
+	if p.truncated {
+		return
+	}
+	if len(p.pending.Message)+len(text) > maxLogEntryBytes {
+		p.truncated = true
+		return
+	}
+	p.pending.Message += text
+}
+
+func (p *dockerLogParser) line(l string, partial bool) error {
+	if p.pending != nil && p.partial {
+		// The rest of a line longer than maxLogLineBytes.
+		p.appendPending(l)
+		p.partial = partial
+		return nil
+	}
+
+	e, ok := parseDockerLogLine(l, p.container, p.stream, p.pid, p.hostName)
+	if !ok {
+		// No timestamp: keep it with the previous entry instead of dropping it.
+		if p.pending != nil {
+			p.appendPending("\n" + strings.TrimRight(l, "\r"))
+			p.partial = partial
+		}
+		return nil
+	}
+	if held := p.held; held != nil {
+		p.held = nil
+		if continuationRegex.MatchString(e.Message) && p.continues(e) {
+			p.appendPending("\n" + held.Message)
+			p.inTrace = true
+		} else if err := p.start(*held, false); err != nil {
+			return err
+		}
+	}
+	if p.continues(e) {
+		p.appendPending("\n" + e.Message)
+		p.partial, p.inTrace = partial, true
+		return nil
+	}
+	if !partial && exceptionHeaderRegex.MatchString(e.Message) && p.withinWindow(e) {
+		p.held, p.updated = &e, time.Now()
+		return nil
+	}
+	return p.start(e, partial)
+}
+
+// start emits the pending entry and makes e the pending one.
+func (p *dockerLogParser) start(e LogEntry, partial bool) error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	e.Message = strings.TrimLeft(e.Message, " \t")
+	p.pending, p.partial, p.truncated, p.inTrace, p.updated = &e, partial, false, false, time.Now()
+	return nil
+}
+
+// withinWindow reports whether e was logged soon enough after the pending
+// entry to continue it.
+func (p *dockerLogParser) withinWindow(e LogEntry) bool {
+	return p.pending != nil && !e.Timestamp.Before(p.pending.Timestamp) &&
+		e.Timestamp.Sub(p.pending.Timestamp) <= continuationWindow
+}
+
+// continues reports whether e is a continuation line of the pending entry.
+func (p *dockerLogParser) continues(e LogEntry) bool {
+	if !p.withinWindow(e) {
+		return false
+	}
+	return e.Message == "" || continuationRegex.MatchString(e.Message) ||
+		p.inTrace && traceLineRegex.MatchString(e.Message)
+}
+
+// flush emits the pending entry, and a held exception header after it.
+func (p *dockerLogParser) flush() error {
+	if p.pending == nil {
+		return nil
+	}
+	e := *p.pending
+	if p.truncated {
+		e.Message += " [truncated]"
+	}
+	p.pending = nil
+	if err := p.emit(e); err != nil {
+		return err
+	}
+	if held := p.held; held != nil {
+		// No stack frames followed: the header is an entry of its own.
+		p.held = nil
+		return p.emit(*held)
+	}
+	return nil
+}
+
+// dockerLogReader turns a container's log stream into entries tagged with
+// the stream they came from.
+type dockerLogReader struct {
+	mu     sync.Mutex
+	stdout *dockerLogParser
+	stderr *dockerLogParser
+}
+
+// newDockerLogReader returns a reader passing every assembled entry to emit.
+func newDockerLogReader(container string, pid int, hostName string, emit func(LogEntry) error) *dockerLogReader {
+	parser := func(stream string) *dockerLogParser {
+		return &dockerLogParser{container: container, stream: stream, hostName: hostName, pid: pid, emit: emit}
+	}
+	return &dockerLogReader{stdout: parser("stdout"), stderr: parser("stderr")}
+}
+
+// locked serializes the parsers, which share emit with FlushIdle.
+func (dr *dockerLogReader) locked(p *dockerLogParser) *lineWriter {
+	return &lineWriter{fn: func(l string, partial bool) error {
+		dr.mu.Lock()
+		defer dr.mu.Unlock()
+		return p.line(l, partial)
+	}}
+}
+
+// Read consumes a log stream, multiplexed unless tty is set, and emits the
+// last held entries when it ends.
+func (dr *dockerLogReader) Read(r io.Reader, tty bool) error {
+	stdout, stderr := dr.locked(dr.stdout), dr.locked(dr.stderr)
+	if err := DemuxDockerStream(r, tty, stdout, stderr); err != nil {
+		return err
+	}
+	if err := stdout.Flush(); err != nil {
+		return err
+	}
+	if err := stderr.Flush(); err != nil {
+		return err
+	}
+	return dr.FlushIdle(0)
+}
+
+// FlushIdle emits held entries that have not changed for at least age, so a
+// followed stream does not hold the last entry until the next line arrives.
+func (dr *dockerLogReader) FlushIdle(age time.Duration) error {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	for _, p := range []*dockerLogParser{dr.stdout, dr.stderr} {
+		if p.pending == nil || p.partial && age > 0 || time.Since(p.updated) < age {
+			continue
+		}
+		if err := p.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
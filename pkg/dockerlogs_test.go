@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readDockerLogsFile parses a recorded `docker logs --timestamps` file.
+// stderrFile, if set, is sent as stderr frames interleaved with stdout.
+func readDockerLogsFile(t *testing.T, file, stderrFile string, tty bool) []LogEntry {
+	t.Helper()
+	load := func(name string) string {
+		data, err := os.ReadFile(filepath.Join("testdata", "docker_logs", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	var stream bytes.Buffer
+	if tty {
+		stream.WriteString(load(file))
+	} else {
+		stdout := strings.SplitAfter(load(file), "\n")
+		var stderr []string
+		if stderrFile != "" {
+			stderr = strings.SplitAfter(load(stderrFile), "\n")
+		}
+		for i := 0; i < len(stdout) || i < len(stderr); i++ {
+			if i < len(stdout) && stdout[i] != "" {
+				stream.Write(dockerFrame(1, stdout[i]))
+			}
+			if i < len(stderr) && stderr[i] != "" {
+				stream.Write(dockerFrame(2, stderr[i]))
+			}
+		}
+	}
+
+	var entries []LogEntry
+	err := newDockerLogReader("app", 7, "vm", func(e LogEntry) error {
+		entries = append(entries, e)
+		return nil
+	}).Read(&stream, tty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestDockerLogContinuations(t *testing.T) {
+	tests := []struct {
+		file   string
+		stderr string
+		tty    bool
+		want   []string // "<stream> <message>"
+	}{
+		{file: "java_stacktrace.log", want: []string{
+			"stdout 2025-08-27 07:58:24.101 ERROR 1 --- [main] o.s.boot.SpringApplication : Application run failed\n" +
+				"java.lang.IllegalStateException: Failed to load ApplicationContext\n" +
+				"\tat org.springframework.boot.SpringApplication.run(SpringApplication.java:315)\n" +
+				"\tat com.example.App.main(App.java:10)\n" +
+				"Caused by: java.net.ConnectException: Connection refused\n" +
+				"\t... 12 more",
+			"stdout 2025-08-27 07:58:25.000  INFO 1 --- [main] shutting down",
+		}},
+		{file: "java_exception_no_frames.log", want: []string{
+			"stdout request failed",
+			"stdout java.io.IOException: Connection reset",
+			"stdout retrying",
+			"stdout java.util.concurrent.TimeoutException",
+		}},
+		{file: "python_traceback.log", want: []string{
+			"stdout ERROR:root:request failed\n" +
+				"Traceback (most recent call last):\n" +
+				"  File \"/app/main.py\", line 12, in handler\n" +
+				"    raise ValueError(\"bad input\")\n" +
+				"ValueError: bad input",
+			"stdout INFO:root:retrying",
+		}},
+		{file: "go_panic.log", want: []string{
+			"stdout panic: runtime error: index out of range [3] with length 3\n" +
+				"\n" +
+				"goroutine 1 [running]:\n" +
+				"main.main()\n" +
+				"\t/app/main.go:8 +0x1d",
+		}},
+		{file: "no_timestamp.log", want: []string{
+			"stdout first\na line without timestamp",
+			"stdout indented after a pause",
+		}},
+		{file: "tty_crlf.log", tty: true, want: []string{
+			"stdout started",
+			"stdout indented, but logged much later",
+		}},
+		{file: "python_traceback.log", stderr: "go_panic.log", want: []string{
+			"stdout ERROR:root:request failed\n" +
+				"Traceback (most recent call last):\n" +
+				"  File \"/app/main.py\", line 12, in handler\n" +
+				"    raise ValueError(\"bad input\")\n" +
+				"ValueError: bad input",
+			"stderr panic: runtime error: index out of range [3] with length 3\n" +
+				"\n" +
+				"goroutine 1 [running]:\n" +
+				"main.main()\n" +
+				"\t/app/main.go:8 +0x1d",
+			"stdout INFO:root:retrying",
+		}},
+	}
+	for _, tc := range tests {
+		name := tc.file
+		if tc.stderr != "" {
+			name += "+" + tc.stderr
+		}
+		t.Run(name, func(t *testing.T) {
+			entries := readDockerLogsFile(t, tc.file, tc.stderr, tc.tty)
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Stream+" "+e.Message)
+				if e.Source != "app" || e.PID != 7 || e.Timestamp.IsZero() {
+					t.Errorf("entry metadata: %+v", e)
+				}
+			}
+			// Streams are assembled independently; compare per stream order.
+			if !sameEntriesPerStream(got, tc.want) {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+// sameEntriesPerStream reports whether got and want hold the same entries in
+// the same order within each stream.
+func sameEntriesPerStream(got, want []string) bool {
+	split := func(entries []string) map[string][]string {
+		m := map[string][]string{}
+		for _, e := range entries {
+			stream, _, _ := strings.Cut(e, " ")
+			m[stream] = append(m[stream], e)
+		}
+		return m
+	}
+	g, w := split(got), split(want)
+	if len(got) != len(want) || len(g) != len(w) {
+		return false
+	}
+	for stream, entries := range w {
+		if strings.Join(g[stream], "\x00") != strings.Join(entries, "\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDockerLogLongLine(t *testing.T) {
+	long := strings.Repeat("x", 2*maxLogLineBytes+123)
+	var stream bytes.Buffer
+	stream.Write(dockerFrame(1, "2025-08-27T07:58:24Z "+long[:maxLogLineBytes/2]))
+	stream.Write(dockerFrame(1, long[maxLogLineBytes/2:]+"\n2025-08-27T07:58:25Z next\n"))
+
+	var entries []LogEntry
+	err := newDockerLogReader("app", 0, "vm", func(e LogEntry) error {
+		entries = append(entries, e)
+		return nil
+	}).Read(&stream, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Message != long || entries[1].Message != "next" {
+		t.Errorf("got %d entries, first %d bytes", len(entries), len(entries[0].Message))
+	}
+}
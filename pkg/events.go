@@ -0,0 +1,340 @@
+// pkg/events.go
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// containerEventActions are the Docker events recorded in the history.
+var containerEventActions = []string{"start", "restart", "stop", "die", "oom", "health_status"}
+
+// eventsRetryInterval is the pause before a lost Docker event stream is reopened.
+const eventsRetryInterval = 5 * time.Second
+
+// ContainerEvent is a lifecycle or health change of a container.
+type ContainerEvent struct {
+	Time      time.Time `json:"time"`
+	Container string    `json:"container"`
+	ID        string    `json:"id"`
+	Image     string    `json:"image,omitempty"`
+	Action    string    `json:"action"`              // start, restart, stop, die, oom or health_status
+	Health    string    `json:"health,omitempty"`    // healthy, unhealthy or starting for health_status
+	ExitCode  *int      `json:"exit_code,omitempty"` // for die
+}
+
+// newContainerEvent converts a Docker event message.
+func newContainerEvent(ev DockerEvent) ContainerEvent {
+	e := ContainerEvent{
+		Time:      time.Unix(0, ev.TimeNano).UTC(),
+		Container: ev.Actor.Attributes["name"],
+		ID:        ev.Actor.ID,
+		Image:     ev.Actor.Attributes["image"],
+		Action:    ev.Action,
+	}
+	if action, health, ok := strings.Cut(ev.Action, ":"); ok {
+		e.Action, e.Health = action, strings.TrimSpace(health)
+	}
+	if code, err := strconv.Atoi(ev.Actor.Attributes["exitCode"]); err == nil && e.Action == "die" {
+		e.ExitCode = &code
+	}
+	return e
+}
+
+// EventHistory keeps the most recent container events in memory and in an
+// NDJSON file, which is rewritten with the in-memory events once it holds
+// twice as many, and passes new events to subscribers.
+type EventHistory struct {
+	mu        sync.Mutex
+	path      string
+	events    []ContainerEvent
+	max       int
+	fileLines int
+	subs      map[chan ContainerEvent]struct{}
+}
+
+// NewEventHistory creates a history backed by path, preloading up to max of
+// the most recent events already present in the file.
+func NewEventHistory(path string, max int) *EventHistory {
+	h := &EventHistory{path: path, max: max, subs: make(map[chan ContainerEvent]struct{})}
+	h.load()
+	return h
+}
+
+// load reads existing events from disk so history survives a restart.
+func (h *EventHistory) load() {
+	if h.path == "" {
+		return
+	}
+	f, err := os.Open(h.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		h.fileLines++
+		var e ContainerEvent
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		h.appendLocked(e)
+	}
+}
+
+func (h *EventHistory) appendLocked(e ContainerEvent) {
+	h.events = append(h.events, e)
+	if len(h.events) > h.max {
+		h.events = h.events[len(h.events)-h.max:]
+	}
+}
+
+// Record stores e, appends it to the history file and notifies subscribers.
+func (h *EventHistory) Record(e ContainerEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.appendLocked(e)
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default: // a slow subscriber misses events rather than blocking the others
+		}
+	}
+	if h.path == "" {
+		return
+	}
+	if h.fileLines >= 2*h.max {
+		if err := h.compactLocked(); err != nil {
+			log.Printf("Events: failed to compact %s: %v", h.path, err)
+		}
+		return
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if dir := filepath.Dir(h.path); dir != "" {
+		_ = os.MkdirAll(dir, 0700)
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Events: failed to open %s: %v", h.path, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err == nil {
+		h.fileLines++
+	}
+}
+
+// compactLocked replaces the history file with the events held in memory.
+func (h *EventHistory) compactLocked() error {
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, e := range h.events {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return err
+	}
+	h.fileLines = len(h.events)
+	return nil
+}
+
+// EventQuery filters events returned by Query. Zero values match everything.
+type EventQuery struct {
+	Container string
+	Action    string
+	Since     time.Time
+	Limit     int
+}
+
+func (q EventQuery) match(e ContainerEvent) bool {
+	return (q.Container == "" || e.Container == q.Container) &&
+		(q.Action == "" || e.Action == q.Action) &&
+		(q.Since.IsZero() || !e.Time.Before(q.Since))
+}
+
+// Query returns matching events, newest last, keeping at most q.Limit of the latest.
+func (h *EventHistory) Query(q EventQuery) []ContainerEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.queryLocked(q)
+}
+
+func (h *EventHistory) queryLocked(q EventQuery) []ContainerEvent {
+	out := []ContainerEvent{}
+	for _, e := range h.events {
+		if q.match(e) {
+			out = append(out, e)
+		}
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out
+}
+
+// Subscribe returns the events matching q so far and a channel receiving new
+// ones until cancel is called.
+func (h *EventHistory) Subscribe(q EventQuery) (past []ContainerEvent, ch <-chan ContainerEvent, cancel func()) {
+	c := make(chan ContainerEvent, 64)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[c] = struct{}{}
+	return h.queryLocked(q), c, func() {
+		h.mu.Lock()
+		delete(h.subs, c)
+		h.mu.Unlock()
+	}
+}
+
+// last returns the time of the newest event.
+func (h *EventHistory) last() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.events) == 0 {
+		return time.Time{}
+	}
+	return h.events[len(h.events)-1].Time
+}
+
+// Watch records container events from the Docker Engine API until ctx is
+// cancelled, reconnecting after errors. After a restart it asks Docker for
+// the events since the newest recorded one. Repeated failures are logged once.
+func (h *EventHistory) Watch(ctx context.Context) {
+	failing := false
+	for {
+		since := h.last()
+		if !since.IsZero() {
+			since = since.Add(time.Nanosecond)
+		}
+		err := Docker.ContainerEvents(ctx, since, containerEventActions, func(ev DockerEvent) {
+			failing = false
+			h.Record(newContainerEvent(ev))
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && err != io.EOF && !failing {
+			log.Printf("Events: %v", err)
+			failing = true
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventsRetryInterval):
+		}
+	}
+}
+
+// MakeServiceEventsHandler implements /services/events: recent container
+// lifecycle and health events.
+//
+// - ?service=<container>, ?action=<action> filter events
+// - ?since=<RFC3339> returns only newer events
+// - ?limit=<n> caps the number of events returned (default 500)
+// - ?stream=true (or Accept: text/event-stream) → the matching events, then new ones as Server-Sent Events
+func MakeServiceEventsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+
+		q := r.URL.Query()
+		query := EventQuery{Container: q.Get("service"), Action: q.Get("action"), Limit: 500}
+		if l := q.Get("limit"); l != "" {
+			v, err := strconv.Atoi(l)
+			if err != nil || v <= 0 {
+				respondWithError(w, http.StatusBadRequest, "Invalid limit", "limit must be a positive integer")
+				return
+			}
+			query.Limit = v
+		}
+		if s := q.Get("since"); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid since", "since must be an RFC3339 timestamp")
+				return
+			}
+			query.Since = t
+		}
+
+		stream, _ := strconv.ParseBool(q.Get("stream"))
+		if !stream && !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			respondWithJSON(w, http.StatusOK, ContainerEvents.Query(query))
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		// The stream outlives the server's WriteTimeout by design.
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Streaming not supported", err.Error())
+			return
+		}
+		past, events, cancel := ContainerEvents.Subscribe(query)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(e ContainerEvent) {
+			data, _ := json.Marshal(e)
+			writeSSE(w, "event", string(data))
+		}
+		for _, e := range past {
+			send(e)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e := <-events:
+				if !query.match(e) {
+					continue
+				}
+				send(e)
+			case <-keepAlive.C:
+				_, _ = io.WriteString(w, ": keep-alive\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventHistoryWatch(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	mux := fakeDockerMux(t)
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		if f := r.URL.Query().Get("filters"); !strings.Contains(f, `"health_status"`) {
+			t.Errorf("filters = %s", f)
+		}
+		if r.URL.Query().Get("since") != "" {
+			// Reconnects get nothing new; hold the stream open.
+			<-r.Context().Done()
+			return
+		}
+		for i, ev := range []string{
+			`{"Type":"container","Action":"start","Actor":{"ID":"aaa","Attributes":{"name":"web","image":"ghcr.io/acme/web:1.2"}},"timeNano":%d}`,
+			`{"Type":"container","Action":"health_status: unhealthy","Actor":{"ID":"aaa","Attributes":{"name":"web"}},"timeNano":%d}`,
+			`{"Type":"container","Action":"die","Actor":{"ID":"aaa","Attributes":{"name":"web","exitCode":"137"}},"timeNano":%d}`,
+			`{"Type":"container","Action":"start","Actor":{"ID":"bbb","Attributes":{"name":"console"}},"timeNano":%d}`,
+		} {
+			fmt.Fprintf(w, ev+"\n", base.Add(time.Duration(i)*time.Second).UnixNano())
+		}
+	})
+	startFakeDocker(t, mux)
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	h := NewEventHistory(path, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Watch(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !h.last().Equal(base.Add(3*time.Second)) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	got := h.Query(EventQuery{})
+	if len(got) != 3 {
+		t.Fatalf("kept %d events, want 3: %+v", len(got), got)
+	}
+	if got[0].Action != "health_status" || got[0].Health != "unhealthy" || got[0].Container != "web" {
+		t.Errorf("health event = %+v", got[0])
+	}
+	if got[1].Action != "die" || got[1].ExitCode == nil || *got[1].ExitCode != 137 {
+		t.Errorf("die event = %+v", got[1])
+	}
+	if web := h.Query(EventQuery{Container: "web", Limit: 1}); len(web) != 1 || web[0].Action != "die" {
+		t.Errorf("web events = %+v", web)
+	}
+
+	// The file holds all four events; a restart keeps the latest three.
+	if reloaded := NewEventHistory(path, 3).Query(EventQuery{}); len(reloaded) != 3 || reloaded[2].Container != "console" {
+		t.Errorf("reloaded = %+v", reloaded)
+	}
+}
+
+func TestServiceEventsHandler(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Hour)
+	h := NewEventHistory("", 10)
+	h.Record(ContainerEvent{Time: base, Container: "web", ID: "aaa", Action: "start"})
+	h.Record(ContainerEvent{Time: base.Add(time.Minute), Container: "console", ID: "bbb", Action: "oom"})
+	prev := ContainerEvents
+	ContainerEvents = h
+	defer func() { ContainerEvents = prev }()
+
+	rec := httptest.NewRecorder()
+	MakeServiceEventsHandler()(rec, httptest.NewRequest(http.MethodGet, "/services/events?action=oom", nil))
+	var listed []ContainerEvent
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].Container != "console" {
+		t.Fatalf("/services/events = %s (%v)", rec.Body, err)
+	}
+	for _, q := range []string{"limit=0", "since=yesterday"} {
+		rec := httptest.NewRecorder()
+		MakeServiceEventsHandler()(rec, httptest.NewRequest(http.MethodGet, "/services/events?"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", q, rec.Code)
+		}
+	}
+
+	srv := httptest.NewServer(MakeServiceEventsHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "?stream=true&service=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	next := func() ContainerEvent {
+		t.Helper()
+		for lines.Scan() {
+			if data, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+				var e ContainerEvent
+				if err := json.Unmarshal([]byte(data), &e); err != nil {
+					t.Fatal(err)
+				}
+				return e
+			}
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return ContainerEvent{}
+	}
+	if e := next(); e.Action != "start" {
+		t.Errorf("first event = %+v", e)
+	}
+	h.Record(ContainerEvent{Time: base.Add(2 * time.Minute), Container: "console", Action: "die"})
+	h.Record(ContainerEvent{Time: base.Add(3 * time.Minute), Container: "web", Action: "restart"})
+	if e := next(); e.Action != "restart" {
+		t.Errorf("streamed event = %+v", e)
+	}
+}
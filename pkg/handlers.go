@@ -2,14 +2,15 @@ package pkg
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"crypto/tls"
 	"crypto/x509"
 	"embed"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html"
 	"io"
 	"log"
 	"math"
@@ -24,20 +25,28 @@ import (
 	"strings"
 	"text/template"
 	"time"
-
-	"github.com/shirou/gopsutil/mem"
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
 )
 
-// StatusHandler handles the /status endpoint and returns a simple JSON status message.
+// StatusHandler handles the /status endpoint: the orchestrator status with the
+// server's uptime, version, platform, attestation file ages and readiness
+// checks. It answers 503 when the orchestrator reports a failure.
 // Only GET requests are accepted.
 func StatusHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
 		return
 	}
+	response, failed := statusResponse()
+	code := http.StatusOK
+	if failed {
+		code = http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, code, response)
+}
 
+// statusResponse builds the /status body; failed reports whether the
+// orchestrator is in one of the failedStatuses.
+func statusResponse() (response map[string]interface{}, failed bool) {
 	env := EnvValue
 	if env == "" {
 		env = "unknown"
@@ -48,12 +57,29 @@ func StatusHandler(w http.ResponseWriter, r *http.Request) {
 		status = "server_error"
 	}
 
-	response := map[string]string{
-		"status": status,
-		"time":   time.Now().Format(time.RFC3339),
-		"env":    env,
+	ages := attestationFileAges()
+	checks := readinessChecks(status, ages, cachedComposeCheck())
+	response = map[string]interface{}{
+		"status":         status,
+		"time":           time.Now().Format(time.RFC3339),
+		"env":            env,
+		"uptime_seconds": round2(time.Since(startTime).Seconds()),
+		"version":        Version,
+		"platform":       platformInfo(ages),
+		"attestation":    ages, // seconds since each report file was written
+		"ready":          checksOK(checks),
+		"checks":         checks,
+	}
+	// Containers touched through the control endpoints since provisioning.
+	response["tampering"] = Tampering.State()
+	// Certificates in use, so clients can detect rotations and upcoming expiry.
+	if ServerCert != nil {
+		response["tls"] = ServerCert.Info()
+	}
+	if RATLSCert != nil {
+		response["ratls"] = RATLSCert.Info()
 	}
-	respondWithJSON(w, http.StatusOK, response)
+	return response, failedStatuses[status]
 }
 
 // MakeAttestationFileHandler returns an HTTP handler function that reads an attestation file.
@@ -235,6 +261,10 @@ func MakePublicKeyHTMLHandler(filePath, keyType string) http.HandlerFunc {
 }
 
 // MakeDockerComposeFileHandler returns a handler that serves the raw docker-compose file.
+//
+// - ?mask=true → values of secret-looking environment entries replaced by [REDACTED]
+//
+// The X-Compose-SHA256 header always holds the digest of the unmasked file.
 func MakeDockerComposeFileHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Only allow GET requests
@@ -244,31 +274,18 @@ func MakeDockerComposeFileHandler() http.HandlerFunc {
 			return
 		}
 
-		path := DockerComposePath
-		// Ensure the path is configured
-		if path == "" {
-			respondWithError(w, http.StatusInternalServerError,
-				"Configuration error", "SECRETVM_DOCKER_COMPOSE_PATH is not set")
+		content, _, ok := readComposeFile(w, r)
+		if !ok {
 			return
 		}
-
-		// Read the file from disk
-		content, err := os.ReadFile(path)
-		if err != nil {
-			respondWithError(w, http.StatusNotFound,
-				"File not found", fmt.Sprintf("Could not read file %s: %v", path, err))
-			return
-		}
-
-		// Serve as HTML with pre tag to preserve trailing newlines
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `<!DOCTYPE html><html><head><meta charset="utf-8"><style>body{background:#1e1e1e;color:#e0e0e0;margin:0;padding:10px;font-family:monospace}pre{margin:0;white-space:pre}</style></head><body><pre>%s&#8203;</pre><script>document.oncopy=function(e){var s=window.getSelection().toString();if(s.endsWith('\u200B')){e.preventDefault();e.clipboardData.setData('text/plain',s.slice(0,-1))}}</script></body></html>`, html.EscapeString(string(content)))
+		w.Write(content)
 	}
 }
 
 // MakeDockerComposeHTMLHandler returns a handler that renders the docker-compose file in HTML.
+// It accepts the same ?mask=true as the raw file.
 func MakeDockerComposeHTMLHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Only allow GET requests
@@ -278,15 +295,11 @@ func MakeDockerComposeHTMLHandler() http.HandlerFunc {
 			return
 		}
 
-		path := DockerComposePath
 		// Read docker-compose content
-		content, err := os.ReadFile(path)
-		if err != nil {
-			respondWithError(w, http.StatusNotFound,
-				"File not found", fmt.Sprintf("Could not read file %s: %v", path, err))
+		content, _, ok := readComposeFile(w, r)
+		if !ok {
 			return
 		}
-
 		// Prepare data for the template
 		data := struct {
 			Title       string
@@ -318,15 +331,44 @@ func MakeDockerComposeHTMLHandler() http.HandlerFunc {
 
 // ResourceStats holds both raw percentages and GB values as numbers.
 type ResourceStats struct {
-	MemoryUsedGB  float64 `json:"memory_used_gb"`
-	MemoryTotalGB float64 `json:"memory_total_gb"`
-	DiskUsedGB    float64 `json:"disk_used_gb"`
-	DiskTotalGB   float64 `json:"disk_total_gb"`
-	MemoryPercent float64 `json:"memory_percent"`
-	DiskPercent   float64 `json:"disk_percent"`
-	CPUPercent    float64 `json:"cpu_percent"`
+	SampledAt     time.Time `json:"sampled_at"`
+	MemoryUsedGB  float64   `json:"memory_used_gb"`
+	MemoryTotalGB float64   `json:"memory_total_gb"`
+	DiskUsedGB    float64   `json:"disk_used_gb"`
+	DiskTotalGB   float64   `json:"disk_total_gb"`
+	MemoryPercent float64   `json:"memory_percent"`
+	DiskPercent   float64   `json:"disk_percent"`
+	CPUPercent    float64   `json:"cpu_percent"` // over the sampling interval
+
+	SwapUsedGB  float64 `json:"swap_used_gb"`
+	SwapTotalGB float64 `json:"swap_total_gb"`
+	SwapPercent float64 `json:"swap_percent"`
+
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+
+	NetRxBytes       uint64  `json:"net_rx_bytes"` // totals over all interfaces since boot
+	NetTxBytes       uint64  `json:"net_tx_bytes"`
+	NetRxBytesPerSec float64 `json:"net_rx_bytes_per_sec"` // over the sampling interval
+	NetTxBytesPerSec float64 `json:"net_tx_bytes_per_sec"`
+
+	// Byte counts behind the rounded GB fields, exported as-is by /metrics
+	memoryUsed, memoryTotal, swapUsed, swapTotal, diskUsed, diskTotal uint64
+
+	Disks    []DiskStats       `json:"disks"`              // SECRETVM_DISK_MOUNTS and the Docker data root
+	Warnings []ResourceWarning `json:"warnings,omitempty"` // disks above the thresholds, read-only or unreadable
+
+	// Set on ?containers=true
+	Containers      []ContainerResources `json:"containers,omitempty"`
+	ContainersError string               `json:"containers_error,omitempty"` // Docker Engine API unreachable
 }
 
+// MakeResourcesHandler reports the latest sample of VM-wide resource usage
+// taken by the background sampler, without waiting for a new measurement.
+//
+// - ?containers=true adds the CPU, memory, network and block I/O of every
+// running container, measured over the same interval as the VM's CPU usage
 func MakeResourcesHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -335,34 +377,34 @@ func MakeResourcesHandler() http.HandlerFunc {
 			return
 		}
 
-		// Memory stats
-		vm, _ := mem.VirtualMemory() // Total and Used in bytes :contentReference[oaicite:4]{index=4}
-
-		// Disk stats for root
-		du, _ := disk.Usage(FsMountPath) // Total and Used in bytes :contentReference[oaicite:5]{index=5}
-
-		// CPU percent
-		cpus, _ := cpu.Percent(2000*time.Millisecond, false)
-		cpuPct := cpus[0] // aggregate CPU usage :contentReference[oaicite:6]{index=6}
-
-		// Convert bytes → GB and round to 3 decimal places
-		toGB := func(b uint64) float64 {
-			gb := float64(b) / (1024 * 1024 * 1024)
-			return math.Round(gb*1000) / 1000
+		withContainers, _ := strconv.ParseBool(r.URL.Query().Get("containers"))
+		stats, ok := latestResources(withContainers)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(Resources.Interval().Seconds()))))
+			respondWithError(w, http.StatusServiceUnavailable, "No resource sample yet",
+				"The first resource sample is still being taken")
+			return
 		}
+		respondWithJSON(w, http.StatusOK, stats)
+	}
+}
 
-		stats := ResourceStats{
-			MemoryUsedGB:  toGB(vm.Used),
-			MemoryTotalGB: toGB(vm.Total),
-			DiskUsedGB:    toGB(du.Used),
-			DiskTotalGB:   toGB(du.Total),
-			MemoryPercent: vm.UsedPercent,
-			DiskPercent:   du.UsedPercent,
-			CPUPercent:    cpuPct,
+// latestResources returns the /resources body from the latest sample, with
+// the per-container usage when withContainers is set; ok is false until the
+// first sample has been taken.
+func latestResources(withContainers bool) (ResourceStats, bool) {
+	stats, containers, containersErr, ok := Resources.Latest()
+	if !ok {
+		return stats, false
+	}
+	if withContainers {
+		if containersErr != "" {
+			stats.ContainersError = containersErr
+		} else {
+			stats.Containers = containers
 		}
-
-		respondWithJSON(w, http.StatusOK, stats)
 	}
+	return stats, true
 }
 
 //go:embed html/resources.html
@@ -461,8 +503,17 @@ func MakeVMUpdatesHTMLHandler() http.HandlerFunc {
 // - ?service=secretvm → only system logs (journalctl)
 // - ?service={container} → only docker logs for that container
 // - no service param → system logs + all docker logs
+// - ?since=, ?until= → time range (RFC3339, Unix seconds, or a duration ago such as 15m)
+// - ?grep=<regex> → only entries whose message matches
+// - ?level=<level> → only entries at or above debug, info, warning, error or critical
+// - ?format=text|json|ndjson → journalctl-formatted text (default), JSON array, or NDJSON
+// - ?cursor=, ?after= → page forward through the system logs from a journal cursor
+// - ?sign=true → detached ed25519 signature over the body in X-Log-Signature headers (see logsig)
+// - ?history=true → serve from the persistent log store, including removed containers
 //
-// All lines are sorted by timestamp. Output format matches journalctl (no year).
+// All entries are sorted by timestamp and redacted by LogRedactor. The cursor
+// of the last journal entry read is returned in the X-Log-Cursor header and
+// the number of redacted secrets in X-Redactions-Count.
 func MakeVMLogsHandler(secure bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -478,56 +529,132 @@ func MakeVMLogsHandler(secure bool) http.HandlerFunc {
 			}
 		}
 
+		format := r.URL.Query().Get("format")
+		switch format {
+		case "", LogFormatText, LogFormatJSON, LogFormatNDJSON:
+		default:
+			respondWithError(w, http.StatusBadRequest, "Invalid format", "format must be text, json or ndjson")
+			return
+		}
+
+		filter, err := parseLogFilter(r.URL.Query())
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid filter", err.Error())
+			return
+		}
+
+		var signKey ed25519.PrivateKey
+		if sign, _ := strconv.ParseBool(r.URL.Query().Get("sign")); sign {
+			if signKey, err = loadLogSigningKey(); err != nil {
+				respondWithError(w, http.StatusServiceUnavailable, "Signing key not available", err.Error())
+				return
+			}
+		}
+
 		service := r.URL.Query().Get("service")
+		journal := JournalQuery{
+			Units:  LogUnits,
+			Lines:  lines,
+			Cursor: r.URL.Query().Get("cursor"),
+			After:  r.URL.Query().Get("after"),
+			Since:  filter.Since,
+			Until:  filter.Until,
+		}
+		paging := journal.Cursor != "" || journal.After != ""
+		if paging && service != "" && service != "secretvm" {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", "cursor and after only apply to the secretvm system logs")
+			return
+		}
+
+		history, _ := strconv.ParseBool(r.URL.Query().Get("history"))
+		if history && paging {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", "cursor and after cannot be combined with history")
+			return
+		}
 
-		var collected []LogLine
+		var collected []LogEntry
 		hostName, _ := os.Hostname()
 
+		if history {
+			// Serve from the persistent store instead of journalctl and docker
+			collected, err = fetchLogHistory(service, secure, lines, filter)
+			switch {
+			case errors.Is(err, errLogHistoryDisabled):
+				respondWithError(w, http.StatusServiceUnavailable, "Log history not available", err.Error())
+				return
+			case errors.Is(err, errLogSourceNotFound):
+				respondWithError(w, http.StatusNotFound, "Service not found", fmt.Sprintf("No stored logs for %q", service))
+				return
+			case err != nil:
+				respondWithError(w, http.StatusInternalServerError, "Failed to read log history", err.Error())
+				return
+			}
+		}
+
 		// Collect system logs if service is not specified or equals "secretvm"
-		if service == "" || service == "secretvm" {
-			if sysLogs, err := fetchServicesLogs(); err == nil {
-				collected = append(collected, parseJournalOutput(sysLogs)...)
+		if !history && (service == "" || service == "secretvm") {
+			sysLogs, cursor, err := fetchServicesLogs(journal)
+			if err != nil {
+				log.Printf("Failed to read journal: %v", err)
 			}
-			if service == "secretvm" {
-				sort.Slice(collected, func(i, j int) bool { return collected[i].Timestamp.Before(collected[j].Timestamp) })
-				writeLogsResponse(w, collected)
-				return
+			collected = append(collected, sysLogs...)
+			if cursor != "" {
+				w.Header().Set("X-Log-Cursor", cursor)
 			}
 		}
 
-		// Collect docker logs (only in secure mode)
-		if secure {
-			if service != "" && service != "secretvm" {
+		// Collect docker logs (only in secure mode); journal paging leaves them out
+		if secure && service != "secretvm" && !paging && !history {
+			if service != "" {
 				// Logs for a single container
-				if ll, err := fetchDockerLogsForContainer(service, lines, hostName); err == nil {
-					sort.Slice(ll, func(i, j int) bool { return ll[i].Timestamp.Before(ll[j].Timestamp) })
-					writeLogsResponse(w, ll)
+				ll, err := fetchDockerLogsForContainer(service, lines, hostName, filter)
+				if IsDockerNotFound(err) {
+					respondWithError(w, http.StatusNotFound, "Service not found", fmt.Sprintf("No container named %q", service))
 					return
 				}
+				if err == nil {
+					collected = ll
+				}
 			} else {
 				// Logs for all containers
-				if ll, err := fetchDockerLogsAll(lines, hostName); err == nil {
+				if ll, err := fetchDockerLogsAll(lines, hostName, filter); err == nil {
 					collected = append(collected, ll...)
 				}
 			}
 		}
 
-		// Sort combined logs and return
-		sort.Slice(collected, func(i, j int) bool {
+		// Sort, redact, filter and return. Redacting first keeps grep from
+		// probing the content of redacted secrets.
+		sort.SliceStable(collected, func(i, j int) bool {
 			return collected[i].Timestamp.Before(collected[j].Timestamp)
 		})
-		writeLogsResponse(w, collected)
+		redactions := LogRedactor.NewSession().Entries(collected)
+		collected = filterEntries(collected, filter)
+		w.Header().Set("X-Redactions-Count", strconv.Itoa(redactions))
+		if signKey == nil {
+			writeLogsResponse(w, format, collected)
+			return
+		}
+		buf := newBufferedResponse()
+		writeLogsResponse(buf, format, collected)
+		buf.signAndSend(w, r, signKey)
 	}
 }
 
 // MakeServicesHandler implements /services endpoint.
 // It returns a JSON array containing "secretvm" plus all docker containers (running and stopped).
+//
+// - ?detail=true → ServicesDetail with image, digest, state, health, restarts, ports and labels per container
 func MakeServicesHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
 			return
 		}
+		if detail, _ := strconv.ParseBool(r.URL.Query().Get("detail")); detail {
+			respondWithJSON(w, http.StatusOK, listServiceDetails(r.Context()))
+			return
+		}
 		names, err := listAllDockerContainerNames()
 		if err != nil {
 			respondWithJSON(w, http.StatusOK, []string{"secretvm"})
@@ -538,28 +665,27 @@ func MakeServicesHandler() http.HandlerFunc {
 	}
 }
 
-// // MakeVMLiveLogsHandler serves the live-updating HTML page
-// // that polls /logs with a selectable line count.
-// func MakeVMLiveLogsHandler() http.HandlerFunc {
-// 	return func(w http.ResponseWriter, r *http.Request) {
-// 		if r.Method != http.MethodGet {
-// 			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
-// 			return
-// 		}
-// 		data := struct{ Title string }{Title: "Live Docker Container Logs"}
-// 		tmpl, err := template.New("liveLogs").Parse(html.DockerLiveLogsTemplate)
-// 		if err != nil {
-// 			log.Printf("Error parsing live logs template: %v", err)
-// 			http.Error(w, "Internal server error", http.StatusInternalServerError)
-// 			return
-// 		}
-// 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-// 		if err := tmpl.Execute(w, data); err != nil {
-// 			log.Printf("Error executing live logs template: %v", err)
-// 			http.Error(w, "Internal server error", http.StatusInternalServerError)
-// 		}
-// 	}
-// }
+// MakeVMLiveLogsHandler serves the live logs page, which follows /logs/stream.
+func MakeVMLiveLogsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+		data := struct{ Title string }{Title: "Live VM Logs"}
+		tmpl, err := template.New("liveLogs").Parse(htmlpkg.DockerLiveLogsTemplate)
+		if err != nil {
+			log.Printf("Error parsing live logs template: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.Execute(w, data); err != nil {
+			log.Printf("Error executing live logs template: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
 
 // Helper function to respond with a JSON error message.
 func respondWithError(w http.ResponseWriter, code int, error string, details string) {
@@ -606,7 +732,8 @@ type ItaTokenResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
-func fetchItaJwt() ([]ItaTokenResponse, error, int) {
+func fetchItaJwt() (tokens []ItaTokenResponse, err error, code int) {
+	defer observeJWTFetch("ita", time.Now(), &err)
 	if len(ItaKeys) == 0 {
 		return nil, fmt.Errorf("no ITA API keys configured"), http.StatusInternalServerError
 	}
@@ -795,7 +922,8 @@ func MakeItaJwtHTMLHandler() http.HandlerFunc {
 	}
 }
 
-func fetchPocJwt() (string, error, int) {
+func fetchPocJwt() (token string, err error, code int) {
+	defer observeJWTFetch("poc", time.Now(), &err)
 	log.Printf("PoC JWT: Fetching token via get_poc_token.sh")
 
 	quoteFilePath := filepath.Join(ReportDir, CPUAttestationFile)
@@ -4,10 +4,20 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestStatusHandler(t *testing.T) {
+	// Point the handler at a status file reporting a running workload
+	prevStatusPath := StatusPath
+	defer func() { StatusPath = prevStatusPath }()
+	StatusPath = filepath.Join(t.TempDir(), "svm_status")
+	if err := os.WriteFile(StatusPath, []byte("running\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
 	// Create a request to pass to our handler
 	req, err := http.NewRequest("GET", "/status", nil)
 	if err != nil {
@@ -33,16 +43,23 @@ func TestStatusHandler(t *testing.T) {
 	}
 
 	// Check the response body
-	var response map[string]string
+	var response map[string]interface{}
 	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
 		t.Errorf("Failed to parse response body: %v", err)
 	}
 
-	// Verify the status field exists
-	if status, exists := response["status"]; !exists || status != "server is alive" {
+	// Verify the status field reports the orchestrator status
+	if status, exists := response["status"]; !exists || status != "running" {
 		t.Errorf("Response missing or incorrect status field: %v", response)
 	}
 
+	// Verify the extended fields exist
+	for _, field := range []string{"uptime_seconds", "version", "platform", "attestation", "ready", "checks"} {
+		if _, exists := response[field]; !exists {
+			t.Errorf("Response missing %s field: %v", field, response)
+		}
+	}
+
 	// Verify the time field exists
 	if _, exists := response["time"]; !exists {
 		t.Errorf("Response missing time field: %v", response)
@@ -75,7 +92,7 @@ func TestStatusHandlerInvalidMethod(t *testing.T) {
 	}
 
 	// Check the response body
-	var response map[string]string
+	var response map[string]interface{}
 	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
 		t.Errorf("Failed to parse response body: %v", err)
 	}
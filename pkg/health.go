@@ -0,0 +1,187 @@
+// pkg/health.go
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// startTime is when the server started, for the uptime on /status and /healthz.
+var startTime = time.Now()
+
+// Orchestrator states in which the workload has stopped or failed to start;
+// /status answers 503 in these.
+var failedStatuses = map[string]bool{"init_failed": true, "crashed": true, "exited": true, "server_error": true}
+
+// HealthCheck is the result of one readiness check.
+type HealthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// PlatformInfo describes the confidential computing platform of the VM.
+type PlatformInfo struct {
+	TEE  string `json:"tee"`  // "tdx", "sev-snp" or "unknown"
+	GPU  bool   `json:"gpu"`  // whether a GPU attestation report is present
+	Arch string `json:"arch"` // e.g. "amd64"
+}
+
+// attestationFiles are the attestation report files in ReportDir, by type.
+func attestationFiles() []struct{ kind, name string } {
+	return []struct{ kind, name string }{
+		{"cpu", CPUAttestationFile}, {"gpu", GPUAttestationFile}, {"self", SelfAttestationFile},
+	}
+}
+
+// attestationFileAges returns the seconds since each present attestation
+// report file was written, by type.
+func attestationFileAges() map[string]float64 {
+	ages := make(map[string]float64)
+	for _, f := range attestationFiles() {
+		if fi, err := os.Stat(filepath.Join(ReportDir, f.name)); err == nil {
+			ages[f.kind] = round2(time.Since(fi.ModTime()).Seconds())
+		}
+	}
+	return ages
+}
+
+// platformInfo detects the TEE from its guest device, as the upgrade check does.
+func platformInfo(ages map[string]float64) PlatformInfo {
+	p := PlatformInfo{TEE: "unknown", Arch: runtime.GOARCH}
+	if _, err := os.Stat("/dev/sev-guest"); err == nil {
+		p.TEE = "sev-snp"
+	} else if _, err := os.Stat("/dev/tdx_guest"); err == nil {
+		p.TEE = "tdx"
+	}
+	_, p.GPU = ages["gpu"]
+	return p
+}
+
+// readinessChecks reports whether the orchestrator is running, the CPU
+// attestation report is present and every compose service has a running
+// container, taking the compose check as given. Details name no services,
+// as the checks are public.
+func readinessChecks(status string, ages map[string]float64, compose HealthCheck) []HealthCheck {
+	checks := []HealthCheck{{Name: "orchestrator", OK: status == "running", Detail: "status " + status}}
+
+	attestation := HealthCheck{Name: "attestation", OK: true}
+	if _, ok := ages["cpu"]; !ok {
+		attestation = HealthCheck{Name: "attestation", Detail: "CPU attestation report not generated yet"}
+	}
+	checks = append(checks, attestation)
+
+	return append(checks, compose)
+}
+
+// lastComposeCheck is the latest compose check, refreshed by the resource
+// sampler and by /readyz. /status, which anyone may call, reports it instead
+// of reading the compose file and asking Docker on every request.
+var lastComposeCheck struct {
+	mu    sync.Mutex
+	check *HealthCheck
+}
+
+// refreshComposeCheck runs the compose check and caches its result.
+func refreshComposeCheck(ctx context.Context) HealthCheck {
+	check := composeCheck(ctx)
+	lastComposeCheck.mu.Lock()
+	defer lastComposeCheck.mu.Unlock()
+	lastComposeCheck.check = &check
+	return check
+}
+
+// cachedComposeCheck returns the latest compose check, failed until the
+// first one has run.
+func cachedComposeCheck() HealthCheck {
+	lastComposeCheck.mu.Lock()
+	defer lastComposeCheck.mu.Unlock()
+	if lastComposeCheck.check == nil {
+		return HealthCheck{Name: "compose", Detail: "not checked yet"}
+	}
+	return *lastComposeCheck.check
+}
+
+// composeCheck reports whether every service of the compose file has a
+// running container.
+func composeCheck(ctx context.Context) HealthCheck {
+	check := HealthCheck{Name: "compose"}
+	if DockerComposePath == "" {
+		check.Detail = "SECRETVM_DOCKER_COMPOSE_PATH is not set"
+		return check
+	}
+	content, err := os.ReadFile(DockerComposePath)
+	if err != nil {
+		check.Detail = "compose file unavailable"
+		return check
+	}
+	cf, err := parseComposeFile(content)
+	if err != nil {
+		check.Detail = "invalid compose file"
+		return check
+	}
+	containers, err := Docker.ListContainers(ctx, true)
+	if err != nil {
+		check.Detail = "Docker unavailable"
+		return check
+	}
+	running := make(map[string]bool)
+	for _, c := range containers {
+		if c.State == "running" {
+			if service := cf.serviceOf(c); service != "" {
+				running[service] = true
+			}
+		}
+	}
+	check.OK = len(running) == len(cf.Services)
+	check.Detail = fmt.Sprintf("%d of %d services running", len(running), len(cf.Services))
+	return check
+}
+
+// checksOK reports whether every check passed.
+func checksOK(checks []HealthCheck) bool {
+	for _, c := range checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// HealthzHandler implements /healthz: answers 200 as long as the server is
+// serving, for liveness probes.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         "ok",
+		"uptime_seconds": round2(time.Since(startTime).Seconds()),
+	})
+}
+
+// ReadyzHandler implements /readyz: 200 when every readiness check passes,
+// 503 with the failing checks otherwise.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+		return
+	}
+	status, err := getStatus()
+	if err != nil {
+		status = "server_error"
+	}
+	checks := readinessChecks(status, attestationFileAges(), refreshComposeCheck(r.Context()))
+	code, ready := http.StatusOK, "ready"
+	if !checksOK(checks) {
+		code, ready = http.StatusServiceUnavailable, "not_ready"
+	}
+	respondWithJSON(w, code, map[string]interface{}{"status": ready, "checks": checks})
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupHealth points the status file, report directory and compose file at
+// a temp dir, with a status of status and a compose file whose services are
+// the given ones.
+func setupHealth(t *testing.T, status string, services ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	prevStatus, prevReport := StatusPath, ReportDir
+	t.Cleanup(func() { StatusPath, ReportDir = prevStatus, prevReport })
+	StatusPath, ReportDir = filepath.Join(dir, "svm_status"), dir
+	if err := os.WriteFile(StatusPath, []byte(status), 0600); err != nil {
+		t.Fatal(err)
+	}
+	compose := "services:\n"
+	for _, s := range services {
+		compose += "  " + s + ":\n    image: busybox\n"
+	}
+	withComposeFile(t, compose)
+	lastComposeCheck.check = nil
+	t.Cleanup(func() { lastComposeCheck.check = nil })
+	return dir
+}
+
+func serveHealth(t *testing.T, h http.HandlerFunc) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, body
+}
+
+func TestReadyzHandler(t *testing.T) {
+	startFakeDocker(t, fakeDockerMux(t))
+	dir := setupHealth(t, "running", "web")
+
+	// No attestation report yet.
+	code, body := serveHealth(t, ReadyzHandler)
+	if code != http.StatusServiceUnavailable || body["status"] != "not_ready" {
+		t.Fatalf("before the report: %d %v", code, body)
+	}
+	if err := os.WriteFile(filepath.Join(dir, CPUAttestationFile), []byte("quote"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if code, body = serveHealth(t, ReadyzHandler); code != http.StatusOK || body["status"] != "ready" {
+		t.Fatalf("ready: %d %v", code, body)
+	}
+
+	// A compose service without a running container.
+	setupHealth(t, "running", "web", "worker")
+	if err := os.WriteFile(filepath.Join(ReportDir, CPUAttestationFile), []byte("quote"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	code, body = serveHealth(t, ReadyzHandler)
+	checks, _ := body["checks"].([]interface{})
+	if code != http.StatusServiceUnavailable || len(checks) != 3 {
+		t.Fatalf("missing service: %d %v", code, body)
+	}
+	if c := checks[2].(map[string]interface{}); c["name"] != "compose" || c["ok"] != false || c["detail"] != "1 of 2 services running" {
+		t.Errorf("compose check = %v", c)
+	}
+}
+
+func TestStatusUsesCachedComposeCheck(t *testing.T) {
+	mux, listed := fakeDockerMux(t), 0
+	startFakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/json" {
+			listed++
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	setupHealth(t, "running", "web")
+
+	compose := func(body map[string]interface{}) interface{} {
+		checks, _ := body["checks"].([]interface{})
+		if len(checks) != 3 {
+			t.Fatalf("checks = %v", body["checks"])
+		}
+		return checks[2].(map[string]interface{})["detail"]
+	}
+	_, body := serveHealth(t, StatusHandler)
+	if d := compose(body); d != "not checked yet" || body["ready"] != false || listed != 0 {
+		t.Errorf("before a check: %v, docker listed %d times", d, listed)
+	}
+	serveHealth(t, ReadyzHandler)
+	_, body = serveHealth(t, StatusHandler)
+	if d := compose(body); d != "1 of 1 services running" || listed != 1 {
+		t.Errorf("after /readyz: %v, docker listed %d times", d, listed)
+	}
+}
+
+func TestHealthzAndFailedStatus(t *testing.T) {
+	startFakeDocker(t, fakeDockerMux(t))
+	setupHealth(t, "crashed", "web")
+
+	if code, body := serveHealth(t, HealthzHandler); code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("healthz: %d %v", code, body)
+	}
+	code, body := serveHealth(t, StatusHandler)
+	if code != http.StatusServiceUnavailable || body["status"] != "crashed" || body["ready"] != false {
+		t.Errorf("status: %d %v", code, body)
+	}
+	if code, _ := serveHealth(t, ReadyzHandler); code != http.StatusServiceUnavailable {
+		t.Errorf("readyz while crashed: %d", code)
+	}
+}
@@ -1,11 +1,10 @@
 // File: pkg/html/docker_live_logs.go
 package html
 
-// DockerLiveLogsTemplate defines a Docker logs page with
-// radio controls to choose either name or index, an Apply button,
-// auto-refresh after Apply only when scrolled to bottom,
-// and auto-scroll to bottom on Apply.
-// Styles reuse the existing attestation page theme.
+// DockerLiveLogsTemplate defines the live logs page. A service selector is
+// filled from /services; Connect opens an EventSource on /logs/stream and
+// appends lines as they arrive, auto-scrolling only when the view is at the
+// bottom. Styles reuse the existing attestation page theme.
 const DockerLiveLogsTemplate = `<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -129,26 +128,25 @@ const DockerLiveLogsTemplate = `<!DOCTYPE html>
     <header>
       <h1>{{.Title}}</h1>
       <div id="controls">
-        <!-- Choose mode -->
-        <label><input type="radio" name="mode" value="name" checked> By Name</label>
-        <input type="text" id="nameInput" placeholder="e.g test">
-        <label><input type="radio" name="mode" value="index"> By Index</label>
-        <input type="number" id="indexInput" min="0" placeholder="0" disabled>
-        <!-- Lines selector -->
-        <label for="linesSelect">Lines</label>
+        <!-- Service selector, filled from /services -->
+        <label for="serviceSelect">Service</label>
+        <select id="serviceSelect">
+          <option value="">all</option>
+        </select>
+        <!-- Backlog lines selector -->
+        <label for="linesSelect">Backlog</label>
         <select id="linesSelect">
-          <option value="100">100</option>
+          <option value="100" selected>100</option>
           <option value="500">500</option>
-          <option value="1000" selected>1000</option>
+          <option value="1000">1000</option>
         </select>
-        <!-- Apply button -->
-        <button id="applyButton">Apply</button>
+        <!-- Connect / disconnect -->
+        <button id="connectButton">Connect</button>
+        <span id="state">disconnected</span>
       </div>
     </header>
     <!-- Log output area -->
-    <div class="logs-container" id="logs">
-      Choose mode, enter value, then click Apply.
-    </div>
+    <div class="logs-container" id="logs">Choose a service, then click Connect.</div>
     <!-- Copy logs button -->
     <div class="button-container">
       <button class="copy-button" id="copyButton">Copy Logs</button>
@@ -156,80 +154,57 @@ const DockerLiveLogsTemplate = `<!DOCTYPE html>
   </div>
   <div class="toast" id="toast">Logs copied to clipboard</div>
   <script>
-    let refreshInterval = null;
+    const params = new URLSearchParams(window.location.search);
+    const token = params.get('token');
+    const api = (path) => token ? path + (path.includes('?') ? '&' : '?') + 'token=' + encodeURIComponent(token) : path;
+    const maxLines = 5000;
+    const logDiv = document.getElementById('logs');
+    const stateEl = document.getElementById('state');
+    const connectButton = document.getElementById('connectButton');
+    let source = null;
 
-    // Toggle inputs and reset when mode changes
-    document.querySelectorAll('input[name="mode"]').forEach(radio => {
-      radio.addEventListener('change', () => {
-        const byName = document.querySelector('input[name="mode"]:checked').value === 'name';
-        document.getElementById('nameInput').disabled = !byName;
-        document.getElementById('indexInput').disabled = byName;
-        clearInterval(refreshInterval);
-        refreshInterval = null;
-        document.getElementById('logs').textContent = 'Choose mode, enter value, then click Apply.';
+    // Populate the service selector
+    fetch(api('/services')).then(r => r.json()).then(services => {
+      const sel = document.getElementById('serviceSelect');
+      services.forEach(name => {
+        const opt = document.createElement('option');
+        opt.value = name;
+        opt.textContent = name;
+        sel.appendChild(opt);
       });
-    });
+    }).catch(e => console.error(e));
 
-    // Stop auto-refresh if user edits inputs
-    ['nameInput', 'indexInput', 'linesSelect'].forEach(id => {
-      document.getElementById(id).addEventListener('input', () => {
-        clearInterval(refreshInterval);
-        refreshInterval = null;
-      });
-    });
-
-    // Perform fetch + scroll + auto-refresh
-    async function applyFetch() {
-      await fetchLogs();
-      const logDiv = document.getElementById('logs');
-      logDiv.scrollTop = logDiv.scrollHeight;        // auto-scroll on Apply
-      if (refreshInterval) clearInterval(refreshInterval);
-      refreshInterval = setInterval(fetchLogs, 2000);
+    function disconnect() {
+      if (source) source.close();
+      source = null;
+      stateEl.textContent = 'disconnected';
+      connectButton.textContent = 'Connect';
     }
 
-    // Core fetch logic: only updates if user is at bottom
-    async function fetchLogs() {
-      const logDiv = document.getElementById('logs');
-      const atBottom = logDiv.scrollHeight - logDiv.scrollTop <= logDiv.clientHeight;
-      if (!atBottom) return;
-
-      const mode = document.querySelector('input[name="mode"]:checked').value;
+    function connect() {
+      disconnect();
+      const service = document.getElementById('serviceSelect').value;
       const lines = document.getElementById('linesSelect').value;
-      let url = '/docker_logs?lines=' + lines;
-      if (mode === 'name') {
-        const name = document.getElementById('nameInput').value.trim();
-        if (!name) { logDiv.textContent = 'Please enter a container name.'; return; }
-        url += '&name=' + encodeURIComponent(name);
-      } else {
-        const idx = document.getElementById('indexInput').value.trim();
-        if (idx === '') { logDiv.textContent = 'Please enter a container index.'; return; }
-        url += '&index=' + encodeURIComponent(idx);
-      }
-
-      try {
-        const res = await fetch(url);
-        const text = await res.text();
-        if (!res.ok) {
-          try {
-            const errObj = JSON.parse(text);
-            logDiv.textContent = errObj.error + ': ' + errObj.details;
-          } catch {
-            logDiv.textContent = text;
-          }
-          return;
-        }
-        logDiv.textContent = text;
-      } catch (e) {
-        console.error(e);
-        logDiv.textContent = 'Failed to fetch logs: ' + e.message;
-      }
+      let url = '/logs/stream?lines=' + lines;
+      if (service) url += '&service=' + encodeURIComponent(service);
+      logDiv.textContent = '';
+      source = new EventSource(api(url));
+      connectButton.textContent = 'Disconnect';
+      stateEl.textContent = 'connecting';
+      source.onopen = () => { stateEl.textContent = 'live'; };
+      source.onerror = () => { stateEl.textContent = 'reconnecting'; };
+      source.addEventListener('log', (e) => {
+        const atBottom = logDiv.scrollHeight - logDiv.scrollTop <= logDiv.clientHeight + 4;
+        logDiv.appendChild(document.createTextNode(e.data + '\n'));
+        while (logDiv.childNodes.length > maxLines) logDiv.removeChild(logDiv.firstChild);
+        if (atBottom) logDiv.scrollTop = logDiv.scrollHeight;
+      });
     }
 
-    // Bind Apply and Copy buttons
-    document.getElementById('applyButton').addEventListener('click', applyFetch);
+    connectButton.addEventListener('click', () => source ? disconnect() : connect());
+    document.getElementById('serviceSelect').addEventListener('change', () => { if (source) connect(); });
     document.getElementById('copyButton').addEventListener('click', () => {
-      const text = document.getElementById('logs').textContent;
-      navigator.clipboard.writeText(text).then(() => {
+      navigator.clipboard.writeText(logDiv.textContent).then(() => {
         const toast = document.getElementById('toast');
         toast.classList.add('show');
         setTimeout(() => toast.classList.remove('show'), 2000);
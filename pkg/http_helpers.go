@@ -2,22 +2,16 @@
 package pkg
 
 import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// groups: one bit controls both plain and html variants
-var endpointBits = map[string]int{
-	"/logs":                0,
-	"/docker-compose":      1,
-	"/docker-compose.html": 1,
-	"/services":            2,
-	"/vm_upgrades":         3,
-	"/vm_upgrades.html":    3,
-	"/resources":           4,
-	"/resources.html":      4,
-}
-
 // leftmost char -> bit 0
 func bitIsOpen(mask string, idx int) bool {
 	if mask == "" || idx < 0 {
@@ -46,35 +40,109 @@ func extractToken(r *http.Request) string {
 	return ""
 }
 
-func PrivateGuard(h http.HandlerFunc) http.HandlerFunc {
+// writeUnauthorized responds with a plain-text 401 message.
+func writeUnauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusUnauthorized)
+	_, _ = w.Write([]byte(msg))
+}
+
+// writeLockedOut responds with 429 and a Retry-After hint for locked-out clients.
+func writeLockedOut(w http.ResponseWriter, d time.Duration) {
+	secs := int(math.Ceil(d.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_, _ = fmt.Fprintf(w, "Too many failed attempts: retry in %d seconds", secs)
+}
+
+// checkToken validates the request token against AccessToken, enforcing the
+// per-client lockout. It writes the error response itself and returns false
+// when the request must not proceed.
+func checkToken(w http.ResponseWriter, r *http.Request, entry *AuditEntry) bool {
+	if d := Lockout.LockedFor(entry.Client); d > 0 {
+		entry.Outcome = AuditOutcomeLockedOut
+		entry.Identity = tokenIdentity(extractToken(r), false)
+		writeLockedOut(w, d)
+		return false
+	}
+
+	token := extractToken(r)
+	valid := AccessToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(AccessToken)) == 1
+	entry.Identity = tokenIdentity(token, valid)
+	if valid {
+		Lockout.Success(entry.Client)
+		entry.Outcome = AuditOutcomeToken
+		return true
+	}
+
+	entry.Outcome = AuditOutcomeDenied
+	// Only wrong guesses count towards the lockout; a browser simply
+	// visiting a private page without a token must not lock itself out.
+	if token != "" {
+		if d := Lockout.Failure(entry.Client); d > 0 {
+			entry.Detail = fmt.Sprintf("locked out for %s", d)
+			log.Printf("Auth: client %s locked out for %s after repeated failures", entry.Client, d)
+		}
+	}
+	writeUnauthorized(w, "Unauthorized: invalid or missing Bearer/X-Dev-Token/?token")
+	return false
+}
+
+func newAuditEntry(r *http.Request) AuditEntry {
+	return AuditEntry{
+		Time:     time.Now().UTC(),
+		Client:   clientIP(r),
+		Method:   r.Method,
+		Endpoint: r.URL.Path,
+		Identity: "none",
+	}
+}
+
+// PrivateGuard restricts access to endpoints of a private group in private mode.
+// The group is opened by its EndpointsMask bit; otherwise the access token is
+// required. Every decision is written to the audit log.
+func PrivateGuard(group AccessGroup, h http.HandlerFunc) http.HandlerFunc {
+	idx, known := groupBits[group]
 	return func(w http.ResponseWriter, r *http.Request) {
+		entry := newAuditEntry(r)
+		defer func() { Audit.Record(entry) }()
+
 		if !PrivateMode {
+			entry.Outcome = AuditOutcomePublic
 			h.ServeHTTP(w, r)
 			return
 		}
-		idx, ok := endpointBits[r.URL.Path]
-		if !ok {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.Header().Set("X-Content-Type-Options", "nosniff")
-			w.WriteHeader(http.StatusUnauthorized)
-			_, _ = w.Write([]byte("Unauthorized: provide Bearer token, X-Dev-Token, or ?token"))
+		if known && bitIsOpen(EndpointsMask, idx) {
+			entry.Outcome = AuditOutcomeOpen
+			h.ServeHTTP(w, r)
 			return
 		}
 
-		if bitIsOpen(EndpointsMask, idx) {
+		if checkToken(w, r, &entry) {
 			h.ServeHTTP(w, r)
-			return
 		}
+	}
+}
 
-		token := extractToken(r)
-		if AccessToken != "" && token == AccessToken {
-			h.ServeHTTP(w, r)
+// OwnerGuard restricts an endpoint to holders of the access token regardless
+// of private mode and the endpoints mask. If no token is configured the
+// endpoint is unavailable.
+func OwnerGuard(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry := newAuditEntry(r)
+		defer func() { Audit.Record(entry) }()
+
+		if AccessToken == "" {
+			entry.Outcome = AuditOutcomeDenied
+			entry.Detail = "no access token configured"
+			writeUnauthorized(w, "Unauthorized: no access token is configured on this VM")
 			return
 		}
-
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.WriteHeader(http.StatusUnauthorized)
-		_, _ = w.Write([]byte("Unauthorized: invalid or missing Bearer/X-Dev-Token/?token"))
+		if checkToken(w, r, &entry) {
+			h.ServeHTTP(w, r)
+		}
 	}
 }
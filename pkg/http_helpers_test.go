@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrivateGuardLockout(t *testing.T) {
+	prevMode, prevToken, prevMask := PrivateMode, AccessToken, EndpointsMask
+	prevAudit, prevLockout := Audit, Lockout
+	defer func() {
+		PrivateMode, AccessToken, EndpointsMask = prevMode, prevToken, prevMask
+		Audit, Lockout = prevAudit, prevLockout
+	}()
+
+	PrivateMode = true
+	AccessToken = "secret"
+	EndpointsMask = "00000"
+	Audit = NewAuditLog(filepath.Join(t.TempDir(), "audit.log"), 100, 0, 0)
+	Lockout = NewLockoutTracker(3, time.Minute, time.Hour)
+
+	handler := PrivateGuard(GroupLogs, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	call := func(token string) int {
+		req := httptest.NewRequest("GET", "/logs", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if token != "" {
+			req.Header.Set("X-Dev-Token", token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := call(""); code != http.StatusUnauthorized {
+		t.Fatalf("missing token: got %d want %d", code, http.StatusUnauthorized)
+	}
+	for i := 0; i < 3; i++ {
+		if code := call("wrong"); code != http.StatusUnauthorized {
+			t.Fatalf("wrong token attempt %d: got %d want %d", i, code, http.StatusUnauthorized)
+		}
+	}
+	// Locked out now, even with the correct token.
+	if code := call("secret"); code != http.StatusTooManyRequests {
+		t.Fatalf("locked out client: got %d want %d", code, http.StatusTooManyRequests)
+	}
+
+	entries := Audit.Query(AuditQuery{Client: "10.0.0.1"})
+	if len(entries) != 5 {
+		t.Fatalf("audit entries: got %d want 5", len(entries))
+	}
+	if last := entries[len(entries)-1]; last.Outcome != AuditOutcomeLockedOut || last.Endpoint != "/logs" {
+		t.Errorf("unexpected last audit entry: %+v", last)
+	}
+
+	// The audit log is reloaded from disk.
+	reloaded := NewAuditLog(Audit.path, 100, 0, 0)
+	if got := len(reloaded.Query(AuditQuery{})); got != 5 {
+		t.Errorf("reloaded audit entries: got %d want 5", got)
+	}
+}
+
+func TestLockoutTrackerBackoff(t *testing.T) {
+	now := time.Unix(0, 0)
+	lt := NewLockoutTracker(2, time.Second, 5*time.Second)
+	lt.now = func() time.Time { return now }
+
+	if d := lt.Failure("c"); d != 0 {
+		t.Fatalf("first failure: got %v want 0", d)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if d := lt.Failure("c"); d != w {
+			t.Errorf("failure %d: got %v want %v", i+2, d, w)
+		}
+	}
+	lt.Success("c")
+	if d := lt.LockedFor("c"); d != 0 {
+		t.Errorf("after success: got %v want 0", d)
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a := NewAuditLog(path, 100, 400, 2)
+	for i := 0; i < 12; i++ {
+		a.Record(AuditEntry{Client: "10.0.0.1", Endpoint: "/logs", Outcome: AuditOutcomeDenied})
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() > 400 {
+			t.Errorf("%s: %d bytes", name, fi.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than 2 rotated files kept: %v", err)
+	}
+	// Entries are reloaded from the live and the rotated files.
+	reloaded := NewAuditLog(path, 100, 400, 2)
+	if got := len(reloaded.Query(AuditQuery{})); got == 0 || got >= 12 {
+		t.Errorf("reloaded %d entries", got)
+	}
+}
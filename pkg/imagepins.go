@@ -0,0 +1,253 @@
+// pkg/imagepins.go
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Results of comparing a compose service's image with its running containers,
+// from best to worst.
+const (
+	PinMatch      = "match"      // pinned by digest and the container runs that digest
+	PinUnpinned   = "unpinned"   // declared by tag only, so any image could be behind it
+	PinUnresolved = "unresolved" // the image references variables that are not set
+	PinMissing    = "missing"    // no container of the service exists
+	PinDrift      = "drift"      // pinned, but the container runs a different image
+)
+
+var pinSeverity = map[string]int{PinMatch: 0, PinUnpinned: 1, PinUnresolved: 2, PinMissing: 3, PinDrift: 4}
+
+// Labels Docker Compose puts on every container.
+const (
+	composeServiceLabel = "com.docker.compose.service"
+	composeProjectLabel = "com.docker.compose.project"
+)
+
+// ImagePinResult compares one compose service with one of its containers.
+type ImagePinResult struct {
+	Service        string   `json:"service"`
+	Declared       string   `json:"declared"`                  // image as written in the compose file
+	Resolved       string   `json:"resolved,omitempty"`        // image after variable interpolation, if different
+	DeclaredDigest string   `json:"declared_digest,omitempty"` // "sha256:..." when pinned
+	Container      string   `json:"container,omitempty"`
+	RunningImage   string   `json:"running_image,omitempty"` // reference the container was created from
+	ImageID        string   `json:"image_id,omitempty"`
+	RepoDigests    []string `json:"repo_digests,omitempty"`
+	Status         string   `json:"status"`
+	Detail         string   `json:"detail,omitempty"`
+}
+
+// ImagePinReport is the /docker-compose/verify response.
+type ImagePinReport struct {
+	ComposeFile   string           `json:"compose_file"`
+	ComposeSHA256 string           `json:"compose_sha256"`
+	Checked       time.Time        `json:"checked"`
+	Status        string           `json:"status"` // worst status of all services
+	Services      []ImagePinResult `json:"services"`
+}
+
+// splitImageDigest splits "repo[:tag]@sha256:..." into the reference and the
+// digest; the digest is empty for references without one.
+func splitImageDigest(image string) (ref, digest string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
+
+// interpolateImage expands $VAR, ${VAR}, ${VAR:-default} and ${VAR-default}
+// in a compose image from the environment the server loaded, which includes
+// the workload's .env. It returns the names of variables that are unset and
+// have no default.
+func interpolateImage(image string) (string, []string) {
+	var unset []string
+	out := os.Expand(image, func(expr string) string {
+		if expr == "$" {
+			return "$" // "$$" is an escaped dollar sign
+		}
+		name, def, op := expr, "", ""
+		if i := strings.IndexAny(expr, ":-?"); i > 0 {
+			name, op = expr[:i], expr[i:]
+			if strings.HasPrefix(op, ":-") {
+				def, op = op[2:], ":-"
+			} else if strings.HasPrefix(op, "-") {
+				def, op = op[1:], "-"
+			}
+		}
+		v, ok := os.LookupEnv(name)
+		switch {
+		case op == ":-" && v == "", op == "-" && !ok:
+			return def
+		case !ok:
+			unset = append(unset, name)
+		}
+		return v
+	})
+	return out, unset
+}
+
+// imageRepo returns the repository of a reference without tag or digest.
+func imageRepo(image string) string {
+	ref, _ := splitImageDigest(image)
+	// Strip a tag, but not a registry port ("host:5000/app").
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// verifyImagePins checks every service of the compose file read from
+// composePath against the containers the Docker daemon runs for it. digest
+// is the SHA-256 of the file as stored, even when cf was parsed from a masked
+// copy.
+func verifyImagePins(ctx context.Context, composePath, digest string, cf *composeFile) (*ImagePinReport, error) {
+	containers, err := Docker.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImagePinReport{
+		ComposeFile:   composePath,
+		ComposeSHA256: digest,
+		Checked:       time.Now().UTC(),
+		Status:        PinMatch,
+		Services:      []ImagePinResult{},
+	}
+	names := make([]string, 0, len(cf.Services))
+	for name := range cf.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	images := make(map[string]*DockerImageInspect) // by image ID
+	for _, name := range names {
+		svc := cf.Services[name]
+		base := ImagePinResult{Service: name, Declared: svc.Image}
+		image, unset := interpolateImage(svc.Image)
+		if image != svc.Image {
+			base.Resolved = image
+		}
+		_, base.DeclaredDigest = splitImageDigest(image)
+		if strings.HasPrefix(image, "sha256:") {
+			base.DeclaredDigest = image
+		}
+
+		var matched []DockerContainer
+		for _, c := range containers {
+			if cf.serviceOf(c) == name {
+				matched = append(matched, c)
+			}
+		}
+		switch {
+		case svc.Image == "":
+			base.Status, base.Detail = PinUnpinned, "service has no image (built locally)"
+		case len(unset) > 0:
+			base.Status, base.Detail = PinUnresolved, "image uses unset variables "+strings.Join(unset, ", ")
+		}
+		if len(matched) == 0 {
+			if base.Status == "" {
+				base.Status, base.Detail = PinMissing, "no container runs this service"
+			}
+			report.add(base)
+			continue
+		}
+		for _, c := range matched {
+			res := base
+			res.Container = c.Name()
+			res.RunningImage = c.Image
+			res.ImageID = c.ImageID
+			img, ok := images[c.ImageID]
+			if !ok && c.ImageID != "" {
+				if img, err = Docker.InspectImage(ctx, c.ImageID); err != nil {
+					img = nil
+				}
+				images[c.ImageID] = img
+			}
+			if img != nil {
+				res.RepoDigests = img.RepoDigests
+			}
+			if res.Status == "" {
+				res.Status, res.Detail = comparePin(image, c, img)
+			}
+			report.add(res)
+		}
+	}
+	return report, nil
+}
+
+// comparePin compares a declared image with the image a container runs.
+func comparePin(declared string, c DockerContainer, img *DockerImageInspect) (string, string) {
+	// "image: sha256:..." names the local image ID directly; it has no "@".
+	if strings.HasPrefix(declared, "sha256:") {
+		if c.ImageID == declared {
+			return PinMatch, ""
+		}
+		return PinDrift, fmt.Sprintf("container runs image %s", c.ImageID)
+	}
+	_, digest := splitImageDigest(declared)
+	if digest == "" {
+		return PinUnpinned, "declared by tag only"
+	}
+	if img == nil {
+		return PinDrift, "running image could not be inspected"
+	}
+	repo := imageRepo(declared)
+	for _, rd := range img.RepoDigests {
+		r, d := splitImageDigest(rd)
+		if d == digest && imageRepoMatches(r, repo) {
+			return PinMatch, ""
+		}
+	}
+	return PinDrift, fmt.Sprintf("running image %s does not have digest %s", c.ImageID, digest)
+}
+
+// imageRepoMatches reports whether two repositories name the same one once
+// Docker Hub's implicit "docker.io/library/" prefix is taken into account.
+func imageRepoMatches(a, b string) bool {
+	norm := func(r string) string {
+		r = strings.TrimPrefix(r, "docker.io/")
+		r = strings.TrimPrefix(r, "index.docker.io/")
+		return strings.TrimPrefix(r, "library/")
+	}
+	return norm(a) == norm(b)
+}
+
+// add appends a result and updates the overall status.
+func (rep *ImagePinReport) add(res ImagePinResult) {
+	rep.Services = append(rep.Services, res)
+	if pinSeverity[res.Status] > pinSeverity[rep.Status] {
+		rep.Status = res.Status
+	}
+}
+
+// MakeImagePinsHandler implements /docker-compose/verify: a runtime check
+// that the containers run the images pinned in the compose file.
+func MakeImagePinsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+		content, digest, ok := readComposeFile(w, r)
+		if !ok {
+			return
+		}
+		cf, err := parseComposeFile(content)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Invalid compose file", err.Error())
+			return
+		}
+		report, err := verifyImagePins(r.Context(), DockerComposePath, digest, cf)
+		if err != nil {
+			respondWithError(w, http.StatusBadGateway, "Docker unavailable", err.Error())
+			return
+		}
+		respondWithJSON(w, http.StatusOK, report)
+	}
+}
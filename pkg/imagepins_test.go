@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func verifyCompose(t *testing.T, compose string) ImagePinReport {
+	return verifyComposeTarget(t, compose, "/docker-compose/verify")
+}
+
+func verifyComposeTarget(t *testing.T, compose, target string) ImagePinReport {
+	t.Helper()
+	withComposeFile(t, compose)
+	rec := httptest.NewRecorder()
+	MakeImagePinsHandler()(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	var report ImagePinReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	return report
+}
+
+func TestImagePins(t *testing.T) {
+	startFakeDocker(t, fakeDockerMux(t))
+
+	report := verifyCompose(t, `
+services:
+  web:
+    image: ghcr.io/acme/web:1.2@sha256:222
+  console:
+    image: console:latest
+    container_name: console
+  db:
+    image: postgres@sha256:333
+`)
+	got := map[string]string{}
+	for _, s := range report.Services {
+		got[s.Service] = s.Status
+	}
+	if got["web"] != PinMatch || got["console"] != PinUnpinned || got["db"] != PinMissing {
+		t.Errorf("statuses = %v", got)
+	}
+	if report.Status != PinMissing || len(report.ComposeSHA256) != 64 {
+		t.Errorf("report = %+v", report)
+	}
+
+	// The digest is that of the stored file, not of a masked copy.
+	const secret = "services:\n  web:\n    image: acme/web\n    environment:\n      DB_PASSWORD: hunter2\n"
+	report = verifyComposeTarget(t, secret, "/docker-compose/verify?mask=true")
+	if report.ComposeSHA256 != composeDigest([]byte(secret)) {
+		t.Errorf("masked compose_sha256 = %s", report.ComposeSHA256)
+	}
+
+	// The digest of another repository does not count.
+	report = verifyCompose(t, "services:\n  web:\n    image: acme/web@sha256:111\n")
+	if report.Status != PinDrift || report.Services[0].Container != "web" {
+		t.Errorf("drift report = %+v", report)
+	}
+
+	// A local image ID is compared with the container's image ID.
+	report = verifyCompose(t, "services:\n  web:\n    image: sha256:web\n")
+	if report.Status != PinMatch || report.Services[0].DeclaredDigest != "sha256:web" {
+		t.Errorf("image ID report = %+v", report)
+	}
+	report = verifyCompose(t, "services:\n  web:\n    image: sha256:other\n")
+	if report.Status != PinDrift {
+		t.Errorf("other image ID report = %+v", report)
+	}
+
+	// Variables are interpolated from the environment.
+	t.Setenv("WEB_DIGEST", "sha256:222")
+	report = verifyCompose(t, "services:\n  web:\n    image: ghcr.io/acme/web:${WEB_TAG:-1.2}@${WEB_DIGEST}\n")
+	if report.Status != PinMatch || report.Services[0].Resolved != "ghcr.io/acme/web:1.2@sha256:222" {
+		t.Errorf("interpolated report = %+v", report)
+	}
+	report = verifyCompose(t, "services:\n  web:\n    image: ghcr.io/acme/web@${WEB_UNSET_DIGEST}\n")
+	if report.Status != PinUnresolved || report.Services[0].Detail != "image uses unset variables WEB_UNSET_DIGEST" {
+		t.Errorf("unresolved report = %+v", report)
+	}
+
+	// The project named in the file has no containers running.
+	report = verifyCompose(t, "name: other\nservices:\n  web:\n    image: ghcr.io/acme/web:1.2@sha256:222\n")
+	if report.Status != PinMissing {
+		t.Errorf("other project report = %+v", report)
+	}
+}
+
+func TestImagePinsIgnoreOtherProjects(t *testing.T) {
+	mux := fakeDockerMux(t)
+	startFakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		// A "web" service of another project runs an image without the pinned digest.
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"Id": "aaa", "Names": []string{"/web"}, "State": "running", "ImageID": "sha256:web",
+				"Labels": map[string]string{"com.docker.compose.project": "app", "com.docker.compose.service": "web"}},
+			{"Id": "ccc", "Names": []string{"/other-web-1"}, "State": "running", "ImageID": "sha256:other",
+				"Labels": map[string]string{"com.docker.compose.project": "other", "com.docker.compose.service": "web"}},
+		})
+	}))
+
+	report := verifyCompose(t, "services:\n  web:\n    image: ghcr.io/acme/web:1.2@sha256:222\n")
+	if report.Status != PinMatch || len(report.Services) != 1 || report.Services[0].Container != "web" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestImageRepo(t *testing.T) {
+	for image, want := range map[string]string{
+		"nginx":                       "nginx",
+		"nginx:1.25@sha256:abc":       "nginx",
+		"localhost:5000/app":          "localhost:5000/app",
+		"localhost:5000/app:v1":       "localhost:5000/app",
+		"docker.io/library/nginx:1.0": "docker.io/library/nginx",
+	} {
+		if got := imageRepo(image); got != want {
+			t.Errorf("imageRepo(%q) = %q, want %q", image, got, want)
+		}
+	}
+	if !imageRepoMatches("docker.io/library/nginx", "nginx") {
+		t.Error("docker hub prefix not normalized")
+	}
+}
@@ -0,0 +1,160 @@
+// pkg/index.go
+package pkg
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Version is the build version reported by the server; set at link time with
+// -ldflags "-X secret-vm-attest-rest-server/pkg.Version=<version>".
+var Version = "dev"
+
+// IndexResponse is the body of the / endpoint.
+type IndexResponse struct {
+	Service     string         `json:"service"`
+	Version     string         `json:"version"`
+	Env         string         `json:"env"`
+	PrivateMode bool           `json:"private_mode"`
+	WellKnown   string         `json:"well_known"`
+	Endpoints   []EndpointInfo `json:"endpoints"`
+}
+
+// MakeIndexHandler lists the endpoints served by this VM, their content
+// types, whether they are enabled and the access level under the current mask.
+func MakeIndexHandler(reg *Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+
+		env := EnvValue
+		if env == "" {
+			env = "unknown"
+		}
+		respondWithJSON(w, http.StatusOK, IndexResponse{
+			Service:     "secret-vm-attest-rest-server",
+			Version:     Version,
+			Env:         env,
+			PrivateMode: PrivateMode,
+			WellKnown:   WellKnownPrefix + "/",
+			Endpoints:   reg.Index(),
+		})
+	}
+}
+
+// MakeOpenAPIHandler serves an OpenAPI 3.0 document generated from the registered routes.
+func MakeOpenAPIHandler(reg *Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, buildOpenAPI(reg.Index()))
+	}
+}
+
+// tokenSecurity lists the accepted ways of presenting the access token.
+var tokenSecurity = []map[string][]string{
+	{"bearerAuth": {}},
+	{"devToken": {}},
+	{"queryToken": {}},
+}
+
+// pathParamEnums lists the accepted values of path parameters that take
+// one of a fixed set, by parameter name.
+var pathParamEnums = map[string][]string{"action": containerActions}
+
+// pathParameters declares the {name} segments of a path template, which
+// OpenAPI requires to be listed as required path parameters.
+func pathParameters(path string) []map[string]interface{} {
+	var params []map[string]interface{}
+	for _, seg := range strings.Split(path, "/") {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.Trim(seg, "{}"), "...")
+		schema := map[string]interface{}{"type": "string"}
+		if enum, ok := pathParamEnums[name]; ok {
+			schema["enum"] = enum
+		}
+		params = append(params, map[string]interface{}{
+			"name": name, "in": "path", "required": true, "schema": schema,
+		})
+	}
+	return params
+}
+
+// buildOpenAPI converts the endpoint index into an OpenAPI 3.0 document.
+// Vendor extensions carry the access group, current access level and enabled state.
+func buildOpenAPI(endpoints []EndpointInfo) map[string]interface{} {
+	paths := make(map[string]interface{})
+
+	addOperation := func(path string, ep EndpointInfo, contentType string) {
+		ops := make(map[string]interface{})
+		for _, m := range ep.Methods {
+			op := map[string]interface{}{
+				"summary":     ep.Summary,
+				"operationId": operationID(m, path),
+				"tags":        []string{string(ep.Group)},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "Success",
+						"content":     map[string]interface{}{contentType: map[string]interface{}{}},
+					},
+				},
+				"x-access-group": ep.Group,
+				"x-access":       ep.Access,
+				"x-enabled":      ep.Enabled,
+			}
+			if params := pathParameters(path); params != nil {
+				op["parameters"] = params
+			}
+			if ep.Access == AccessLevelToken {
+				op["security"] = tokenSecurity
+				op["responses"].(map[string]interface{})["401"] = map[string]interface{}{"description": "Missing or invalid access token"}
+				op["responses"].(map[string]interface{})["429"] = map[string]interface{}{"description": "Too many failed attempts"}
+			}
+			ops[strings.ToLower(m)] = op
+		}
+		paths[path] = ops
+	}
+
+	for _, ep := range endpoints {
+		addOperation(ep.Path, ep, ep.ContentTypes[0])
+		for _, alias := range ep.Aliases {
+			addOperation(alias, ep, ep.ContentTypes[0])
+		}
+		if ep.HTML != "" {
+			addOperation(ep.HTML, ep, "text/html")
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "SecretVM Attest REST Server",
+			"version":     Version,
+			"description": "Every path is also served under " + WellKnownPrefix + "/.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer"},
+				"devToken":   map[string]string{"type": "apiKey", "in": "header", "name": "X-Dev-Token"},
+				"queryToken": map[string]string{"type": "apiKey", "in": "query", "name": "token"},
+			},
+		},
+	}
+}
+
+// operationID derives a stable identifier such as "get_cpu_html" from a method and path.
+func operationID(method, path string) string {
+	id := strings.Trim(path, "/")
+	if id == "" {
+		id = "index"
+	}
+	id = strings.NewReplacer("/", "_", ".", "_", "-", "_", "{", "", "}", "").Replace(id)
+	return strings.ToLower(method) + "_" + id
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIndexAndOpenAPI(t *testing.T) {
+	prevMode, prevMask := PrivateMode, EndpointsMask
+	defer func() { PrivateMode, EndpointsMask = prevMode, prevMask }()
+	PrivateMode = true
+	EndpointsMask = "01000" // docker-compose open, everything else closed
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	reg := NewRegistry(
+		Route{Path: "/pub", Handler: ok, Group: GroupPublic},
+		Route{Path: "/open", Handler: ok, HTML: ok, Group: GroupDockerCompose},
+		Route{Path: "/closed", Handler: ok, Group: GroupLogs, Aliases: []string{"/closed-alias"}, ContentType: "text/plain"},
+		Route{Path: "/owner/{name}/{action}", Handler: ok, Group: GroupOwner, Methods: []string{http.MethodPost},
+			Enabled: func() bool { return false }},
+		Route{Path: "/asset.png", Handler: ok, Group: GroupPublic, Hidden: true},
+	)
+
+	rec := httptest.NewRecorder()
+	MakeIndexHandler(reg)(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var index IndexResponse
+	if err := json.NewDecoder(rec.Body).Decode(&index); err != nil {
+		t.Fatal(err)
+	}
+	if !index.PrivateMode || index.Version != Version || len(index.Endpoints) != 4 {
+		t.Fatalf("index = %+v", index)
+	}
+	want := map[string]struct {
+		access  string
+		enabled bool
+	}{
+		"/pub":                   {AccessLevelPublic, true},
+		"/open":                  {AccessLevelOpen, true},
+		"/closed":                {AccessLevelToken, true},
+		"/owner/{name}/{action}": {AccessLevelToken, false},
+	}
+	for _, ep := range index.Endpoints {
+		w, found := want[ep.Path]
+		if !found || ep.Access != w.access || ep.Enabled != w.enabled {
+			t.Errorf("%s: access %s enabled %v, want %+v", ep.Path, ep.Access, ep.Enabled, w)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	MakeOpenAPIHandler(reg)(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name     string `json:"name"`
+				In       string `json:"in"`
+				Required bool   `json:"required"`
+				Schema   struct {
+					Enum []string `json:"enum"`
+				} `json:"schema"`
+			} `json:"parameters"`
+			Security  []map[string][]string  `json:"security"`
+			Responses map[string]interface{} `json:"responses"`
+			Access    string                 `json:"x-access"`
+			Group     string                 `json:"x-access-group"`
+			Enabled   bool                   `json:"x-enabled"`
+		} `json:"paths"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	for _, p := range []string{"/pub", "/open", "/open.html", "/closed", "/closed-alias", "/owner/{name}/{action}"} {
+		if _, found := doc.Paths[p]; !found {
+			t.Errorf("path %s missing", p)
+		}
+	}
+	if _, found := doc.Paths["/asset.png"]; found {
+		t.Error("hidden route documented")
+	}
+	if op, found := doc.Paths["/owner/{name}/{action}"]["post"]; !found || op.Enabled || op.Group != "owner" ||
+		len(op.Security) != len(tokenSecurity) || op.Responses["401"] == nil {
+		t.Errorf("owner operation = %+v", doc.Paths["/owner/{name}/{action}"])
+	}
+	params := doc.Paths["/owner/{name}/{action}"]["post"].Parameters
+	if len(params) != 2 || params[0].Name != "name" || params[1].Name != "action" ||
+		params[0].In != "path" || !params[1].Required || len(params[0].Schema.Enum) != 0 ||
+		strings.Join(params[1].Schema.Enum, "|") != "restart|stop|start" {
+		t.Errorf("path parameters = %+v", params)
+	}
+	if op := doc.Paths["/pub"]["get"]; op.Parameters != nil {
+		t.Errorf("parameters on a plain path: %+v", op.Parameters)
+	}
+	if _, found := doc.Paths["/owner/{name}/{action}"]["get"]; found {
+		t.Error("GET documented for a POST-only route")
+	}
+	if op := doc.Paths["/closed-alias"]["get"]; op.Access != AccessLevelToken || len(op.Security) == 0 {
+		t.Errorf("guarded alias = %+v", op)
+	}
+	for _, p := range []string{"/pub", "/open"} {
+		if op := doc.Paths[p]["get"]; op.Security != nil || op.Responses["401"] != nil {
+			t.Errorf("%s documented as guarded: %+v", p, op)
+		}
+	}
+	if op := doc.Paths["/open.html"]["get"]; op.OperationID != "get_open_html" {
+		t.Errorf("operationId = %q", op.OperationID)
+	}
+}
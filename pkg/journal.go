@@ -0,0 +1,204 @@
+// pkg/journal.go
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// journalTimeout bounds a non-following journalctl read.
+const journalTimeout = 30 * time.Second
+
+// JournalQuery selects the systemd journal entries read for /logs.
+//
+// Without a cursor the last Lines entries are returned. With Cursor (the
+// entry itself included) or After (the entry excluded) reading continues
+// forward from that position and stops after Lines entries, so clients can
+// page through the journal with the returned cursor.
+type JournalQuery struct {
+	Units  []string
+	Lines  int
+	Cursor string
+	After  string
+	Since  time.Time
+	Until  time.Time
+}
+
+// journalArgs builds the journalctl arguments for q; follow adds -f.
+// The `--no-pager` flag is required: without it, journalctl may invoke
+// a pager like `less`, which would cause the process to hang or cut output.
+func journalArgs(q JournalQuery, follow bool) []string {
+	args := []string{"-o", "json", "--no-pager"}
+	for _, u := range q.Units {
+		args = append(args, "-u", u)
+	}
+	if !q.Since.IsZero() {
+		args = append(args, "--since", q.Since.Local().Format("2006-01-02 15:04:05"))
+	}
+	if !q.Until.IsZero() {
+		args = append(args, "--until", q.Until.Local().Format("2006-01-02 15:04:05"))
+	}
+	switch {
+	case q.After != "":
+		args = append(args, "--after-cursor="+q.After)
+	case q.Cursor != "":
+		args = append(args, "--cursor="+q.Cursor)
+	case q.Lines >= 0:
+		args = append(args, "-n", strconv.Itoa(q.Lines))
+	}
+	if follow {
+		args = append(args, "-f")
+	}
+	return args
+}
+
+// fetchServicesLogs reads the journal entries selected by q. It returns the
+// entries and the cursor of the last entry read, which is q's own cursor
+// when nothing new was read.
+func fetchServicesLogs(q JournalQuery) ([]LogEntry, string, error) {
+	last := q.After
+	if last == "" {
+		last = q.Cursor
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), journalTimeout)
+	defer cancel()
+
+	var entries []LogEntry
+	err := streamJournal(ctx, q, func(e LogEntry) bool {
+		entries = append(entries, e)
+		last = e.Cursor
+		return len(entries) < q.Lines
+	})
+	return entries, last, err
+}
+
+// streamJournal runs journalctl for q and calls fn with every entry until fn
+// returns false or the output ends. A negative q.Lines reads the whole journal.
+func streamJournal(ctx context.Context, q JournalQuery, fn func(LogEntry) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "journalctl", journalArgs(q, false)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	stopped, readErr := readJournalJSON(stdout, fn)
+	if stopped {
+		// fn had enough: stop journalctl instead of draining its output.
+		cancel()
+	}
+	waitErr := cmd.Wait()
+	if readErr != nil {
+		return readErr
+	}
+	if waitErr != nil && !stopped {
+		return waitErr
+	}
+	return nil
+}
+
+// readJournalJSON parses `journalctl -o json` lines from r and calls fn
+// with each entry; it reports whether fn stopped the reading by returning false.
+func readJournalJSON(r io.Reader, fn func(LogEntry) bool) (bool, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		e, ok := parseJournalJSONLine(sc.Bytes())
+		if !ok {
+			continue
+		}
+		if !fn(e) {
+			return true, nil
+		}
+	}
+	return false, sc.Err()
+}
+
+// journalRecord holds the journal fields we use. Values are strings, except
+// MESSAGE, which journald encodes as a byte array when it is not valid UTF-8.
+type journalRecord struct {
+	Cursor     string          `json:"__CURSOR"`
+	Realtime   string          `json:"__REALTIME_TIMESTAMP"`
+	Hostname   string          `json:"_HOSTNAME"`
+	Identifier string          `json:"SYSLOG_IDENTIFIER"`
+	Unit       string          `json:"_SYSTEMD_UNIT"`
+	PID        string          `json:"_PID"`
+	Priority   string          `json:"PRIORITY"`
+	Message    json.RawMessage `json:"MESSAGE"`
+}
+
+// journalPriorityLevels maps syslog priorities (0 emerg … 7 debug) to levels.
+var journalPriorityLevels = map[string]string{
+	"0": LevelCritical, "1": LevelCritical, "2": LevelCritical,
+	"3": LevelError,
+	"4": LevelWarning,
+	"5": LevelInfo, "6": LevelInfo,
+	"7": LevelDebug,
+}
+
+// parseJournalJSONLine parses a single `journalctl -o json` line.
+func parseJournalJSONLine(line []byte) (LogEntry, bool) {
+	var rec journalRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return LogEntry{}, false
+	}
+	usec, err := strconv.ParseInt(rec.Realtime, 10, 64)
+	if err != nil {
+		return LogEntry{}, false
+	}
+	e := LogEntry{
+		Timestamp:  time.UnixMicro(usec),
+		Host:       rec.Hostname,
+		Source:     rec.Unit,
+		Identifier: rec.Identifier,
+		Message:    journalMessage(rec.Message),
+		Cursor:     rec.Cursor,
+	}
+	if e.Source == "" {
+		e.Source = rec.Identifier
+	}
+	e.PID, _ = strconv.Atoi(rec.PID)
+
+	// Services usually log everything at the default priority (info), so a
+	// level keyword in the message takes precedence over it.
+	e.Level = journalPriorityLevels[rec.Priority]
+	if e.Level == "" || e.Level == LevelInfo {
+		if l := detectLevel(e.Message); l != "" {
+			e.Level = l
+		}
+	}
+	return e, true
+}
+
+// journalMessage decodes MESSAGE, which is either a JSON string or an array
+// of bytes. Binary payloads become the "[NB blob data]" placeholder that
+// journalctl prints in its text formats.
+func journalMessage(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var b []byte
+	var ints []int
+	if json.Unmarshal(raw, &ints) != nil {
+		return ""
+	}
+	for _, v := range ints {
+		b = append(b, byte(v))
+	}
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return fmt.Sprintf("[%dB blob data]", len(b))
+}
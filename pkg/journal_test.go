@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJournalJSONLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		level   string
+		message string
+	}{
+		{"string message",
+			`{"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1756281504123456","_HOSTNAME":"vm","SYSLOG_IDENTIFIER":"secret-vm-attest-rest","_PID":"812","PRIORITY":"6","MESSAGE":"ERROR failed to read quote"}`,
+			LevelError, "ERROR failed to read quote"},
+		{"priority wins over keyword",
+			`{"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1756281504123456","_HOSTNAME":"vm","SYSLOG_IDENTIFIER":"secret-vm-attest-rest","_PID":"812","PRIORITY":"2","MESSAGE":"info: giving up"}`,
+			LevelCritical, "info: giving up"},
+		{"utf-8 byte array",
+			`{"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1756281504123456","_HOSTNAME":"vm","SYSLOG_IDENTIFIER":"secret-vm-attest-rest","_PID":"812","PRIORITY":"4","MESSAGE":[104,105,27,91,48,109]}`,
+			LevelWarning, "hi\x1b[0m"},
+		{"binary byte array",
+			`{"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1756281504123456","_HOSTNAME":"vm","SYSLOG_IDENTIFIER":"secret-vm-attest-rest","_PID":"812","PRIORITY":"6","MESSAGE":[255,0,1]}`,
+			LevelInfo, "[3B blob data]"},
+	}
+	for _, tc := range tests {
+		e, ok := parseJournalJSONLine([]byte(tc.line))
+		if !ok {
+			t.Errorf("%s: not parsed", tc.name)
+			continue
+		}
+		if e.Level != tc.level || e.Message != tc.message {
+			t.Errorf("%s: level %q message %q", tc.name, e.Level, e.Message)
+		}
+		if !e.Timestamp.Equal(time.Date(2025, 8, 27, 7, 58, 24, 123456000, time.UTC)) ||
+			e.Host != "vm" || e.Source != "secret-vm-attest-rest" || e.PID != 812 || e.Cursor != "s=1;i=1" {
+			t.Errorf("%s: metadata %+v", tc.name, e)
+		}
+	}
+
+	// The unit is the source; the identifier is kept for the text format.
+	e, _ := parseJournalJSONLine([]byte(`{"__REALTIME_TIMESTAMP":"1756281504123456","_HOSTNAME":"vm","_SYSTEMD_UNIT":"secret-vm-attest-rest.service","SYSLOG_IDENTIFIER":"attest","_PID":"812","MESSAGE":"up"}`))
+	if e.Source != "secret-vm-attest-rest.service" || e.Identifier != "attest" || !strings.Contains(e.Text(), " vm attest[812]: up") {
+		t.Errorf("unit and identifier: %+v, %q", e, e.Text())
+	}
+
+	if _, ok := parseJournalJSONLine([]byte(`{"MESSAGE":"no timestamp"}`)); ok {
+		t.Error("entry without timestamp parsed")
+	}
+}
+
+func TestReadJournalJSONStopsAtLimit(t *testing.T) {
+	var b strings.Builder
+	for _, c := range []string{"a", "b", "c"} {
+		b.WriteString(`{"__CURSOR":"` + c + `","__REALTIME_TIMESTAMP":"1756281504000000","MESSAGE":"` + c + `"}` + "\n")
+	}
+	var cursors []string
+	stopped, err := readJournalJSON(strings.NewReader(b.String()), func(e LogEntry) bool {
+		cursors = append(cursors, e.Cursor)
+		return len(cursors) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stopped || strings.Join(cursors, ",") != "a,b" {
+		t.Errorf("stopped %v cursors %v", stopped, cursors)
+	}
+}
+
+func TestJournalArgs(t *testing.T) {
+	units := []string{"a", "b"}
+	tests := []struct {
+		q    JournalQuery
+		want string
+	}{
+		{JournalQuery{Units: units, Lines: 50}, "-o json --no-pager -u a -u b -n 50"},
+		{JournalQuery{Units: units, Lines: 50, Cursor: "s=1"}, "-o json --no-pager -u a -u b --cursor=s=1"},
+		{JournalQuery{Units: units, Lines: 50, After: "s=1", Cursor: "s=0"}, "-o json --no-pager -u a -u b --after-cursor=s=1"},
+	}
+	for _, tc := range tests {
+		if got := strings.Join(journalArgs(tc.q, false), " "); got != tc.want {
+			t.Errorf("got %q want %q", got, tc.want)
+		}
+	}
+}
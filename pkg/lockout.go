@@ -0,0 +1,103 @@
+// pkg/lockout.go
+package pkg
+
+import (
+	"sync"
+	"time"
+)
+
+// failureRecord tracks consecutive failed token attempts for a single client.
+type failureRecord struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LockoutTracker implements per-client exponential lockout after repeated
+// failed authentication attempts. Once a client reaches maxFailures, each
+// further failure doubles the lockout, starting at base and capped at maxLock.
+type LockoutTracker struct {
+	mu          sync.Mutex
+	clients     map[string]*failureRecord
+	maxFailures int
+	base        time.Duration
+	maxLock     time.Duration
+	now         func() time.Time
+}
+
+// NewLockoutTracker creates a tracker with the given threshold and lockout bounds.
+func NewLockoutTracker(maxFailures int, base, maxLock time.Duration) *LockoutTracker {
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+	return &LockoutTracker{
+		clients:     make(map[string]*failureRecord),
+		maxFailures: maxFailures,
+		base:        base,
+		maxLock:     maxLock,
+		now:         time.Now,
+	}
+}
+
+// LockedFor returns how long the client remains locked out, or zero if it may try again.
+func (t *LockoutTracker) LockedFor(client string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rec, ok := t.clients[client]
+	if !ok {
+		return 0
+	}
+	if d := rec.lockedUntil.Sub(t.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Failure records a failed attempt and returns the resulting lockout (zero if none).
+func (t *LockoutTracker) Failure(client string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.pruneLocked(now)
+
+	rec, ok := t.clients[client]
+	if !ok {
+		rec = &failureRecord{}
+		t.clients[client] = rec
+	}
+	rec.failures++
+	rec.lastFailure = now
+
+	if rec.failures < t.maxFailures {
+		return 0
+	}
+
+	lock := t.base
+	for i := t.maxFailures; i < rec.failures && lock < t.maxLock; i++ {
+		lock *= 2
+	}
+	if lock > t.maxLock {
+		lock = t.maxLock
+	}
+	rec.lockedUntil = now.Add(lock)
+	return lock
+}
+
+// Success clears the failure history of a client.
+func (t *LockoutTracker) Success(client string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.clients, client)
+}
+
+// pruneLocked forgets clients whose lockout expired and who have been quiet
+// for longer than the maximum lockout, bounding memory under scanning.
+func (t *LockoutTracker) pruneLocked(now time.Time) {
+	for client, rec := range t.clients {
+		if now.After(rec.lockedUntil) && now.Sub(rec.lastFailure) > t.maxLock {
+			delete(t.clients, client)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// pkg/logcollector.go
+package pkg
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	// journalSource is the store source holding the logs of LogUnits.
+	journalSource = "secretvm"
+	// collectorScanInterval is how often new containers are looked for.
+	collectorScanInterval = 10 * time.Second
+	// collectorRetryInterval is the pause before a failed journal follow is restarted.
+	collectorRetryInterval = 5 * time.Second
+	// collectorBackfillLines is how much of the journal is stored when the store is empty.
+	collectorBackfillLines = 1000
+)
+
+var (
+	errLogHistoryDisabled = errors.New("log history is not enabled (SECRETVM_LOG_STORE_DIR is empty)")
+	errLogSourceNotFound  = errors.New("no stored logs for this service")
+)
+
+// LogCollector tails the journal of LogUnits and, in secure mode, every
+// running container, and passes their entries to a LogStore, so their logs
+// outlive the containers, and to the alert rules. After a restart it resumes
+// from the last stored entry of each source.
+type LogCollector struct {
+	store    *LogStore    // nil when log history is disabled
+	alerts   *AlertEngine // nil when no alert rules are configured
+	secure   bool
+	hostName string
+	started  time.Time
+
+	mu        sync.Mutex
+	following map[string]bool     // containers currently tailed
+	last      map[string]LogEntry // last entry collected per source
+}
+
+// NewLogCollector returns a collector feeding store and alerts; either may be nil.
+func NewLogCollector(store *LogStore, alerts *AlertEngine, secure bool) *LogCollector {
+	hostName, _ := os.Hostname()
+	return &LogCollector{
+		store:     store,
+		alerts:    alerts,
+		secure:    secure,
+		hostName:  hostName,
+		started:   time.Now(),
+		following: make(map[string]bool),
+		last:      make(map[string]LogEntry),
+	}
+}
+
+// handle passes an entry of source to the store and the alert rules.
+func (c *LogCollector) handle(source string, e LogEntry) error {
+	c.mu.Lock()
+	c.last[source] = e
+	c.mu.Unlock()
+	if c.alerts != nil {
+		c.alerts.Observe(source, e)
+	}
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Append(source, e)
+}
+
+// resume returns the last entry collected from source, in this run or, with
+// a store, a previous one.
+func (c *LogCollector) resume(source string) (LogEntry, bool) {
+	c.mu.Lock()
+	e, ok := c.last[source]
+	c.mu.Unlock()
+	if ok || c.store == nil {
+		return e, ok
+	}
+	return c.store.Last(source)
+}
+
+// Run collects logs until ctx is cancelled.
+func (c *LogCollector) Run(ctx context.Context) {
+	go c.collectJournal(ctx)
+	if !c.secure {
+		<-ctx.Done()
+		return
+	}
+	t := time.NewTicker(collectorScanInterval)
+	defer t.Stop()
+	for {
+		c.scanContainers(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// collectJournal follows the journal, restarting journalctl when it exits.
+// Store errors are not written to the log: it is part of the journal being
+// collected and would feed back into the store.
+func (c *LogCollector) collectJournal(ctx context.Context) {
+	for {
+		q := JournalQuery{Units: LogUnits, Lines: collectorBackfillLines}
+		if c.store == nil {
+			q.Lines = 0 // nothing to backfill
+		}
+		if last, ok := c.resume(journalSource); ok && last.Cursor != "" {
+			q.After = last.Cursor
+		}
+		entries := make(chan LogEntry, 256)
+		go func() {
+			defer close(entries)
+			cmd := exec.CommandContext(ctx, "journalctl", journalArgs(q, true)...)
+			followCommand(ctx, cmd, func(line, stream string) (LogEntry, bool) {
+				if stream != "stdout" {
+					return LogEntry{}, false
+				}
+				return parseJournalJSONLine([]byte(line))
+			}, entries)
+		}()
+		for e := range entries {
+			_ = c.handle(journalSource, e)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(collectorRetryInterval):
+		}
+	}
+}
+
+// scanContainers starts tailing running containers that are not followed yet.
+func (c *LogCollector) scanContainers(ctx context.Context) {
+	containers, err := Docker.ListContainers(ctx, false)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ct := range containers {
+		name := ct.Name()
+		if c.following[name] || !storeSourceRegex.MatchString(name) || name == journalSource {
+			continue
+		}
+		c.following[name] = true
+		go c.collectContainer(ctx, name)
+	}
+}
+
+// collectContainer tails a container until its log stream ends, which
+// happens when the container stops; the next scan picks it up again.
+func (c *LogCollector) collectContainer(ctx context.Context, name string) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.following, name)
+		c.mu.Unlock()
+	}()
+
+	opts := DockerLogsOptions{Follow: true, Tail: -1}
+	var after time.Time
+	if last, ok := c.resume(name); ok {
+		after = last.Timestamp
+		opts.Since = after
+	} else if c.store == nil {
+		// Without a store only what is logged from now on matters.
+		after = c.started
+		opts.Since = after
+	}
+	err := followContainerLogs(ctx, name, opts, c.hostName, func(e LogEntry) error {
+		// Since is inclusive; skip what is already stored.
+		if !e.Timestamp.After(after) {
+			return nil
+		}
+		return c.handle(name, e)
+	})
+	if err != nil && ctx.Err() == nil && !IsDockerNotFound(err) {
+		log.Printf("Log collector: %s: %v", name, err)
+	}
+}
+
+// fetchLogHistory returns the last lines stored entries of service between
+// f.Since and f.Until, or of every stored source when service is empty.
+// Container logs are only served in secure mode.
+func fetchLogHistory(service string, secure bool, lines int, f LogFilter) ([]LogEntry, error) {
+	if LogHistory == nil {
+		return nil, errLogHistoryDisabled
+	}
+	sources, err := LogHistory.Sources()
+	if err != nil {
+		return nil, err
+	}
+	var selected []string
+	for _, src := range sources {
+		if src != journalSource && !secure {
+			continue
+		}
+		if service == "" || service == src {
+			selected = append(selected, src)
+		}
+	}
+	if service != "" && len(selected) == 0 {
+		return nil, errLogSourceNotFound
+	}
+
+	var collected []LogEntry
+	for _, src := range selected {
+		entries, err := LogHistory.Tail(src, lines, f.Since, f.Until)
+		if err != nil {
+			return nil, err
+		}
+		collected = append(collected, entries...)
+	}
+	return collected, nil
+}
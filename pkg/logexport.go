@@ -0,0 +1,321 @@
+// pkg/logexport.go
+package pkg
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ExportFile describes one member of a /logs/export archive.
+type ExportFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Error  string `json:"error,omitempty"` // set when the member is incomplete
+}
+
+// ExportManifest is written as manifest.json, the last member of the archive.
+type ExportManifest struct {
+	Created    time.Time    `json:"created"`
+	Host       string       `json:"host"`
+	Version    string       `json:"version"`
+	Query      string       `json:"query,omitempty"`
+	Redactions int          `json:"redactions"`
+	Files      []ExportFile `json:"files"`
+}
+
+// logExporter writes the members of an export archive. Each member is first
+// spooled to a temporary file, because tar headers need the size up front;
+// only one member is held at a time and the archive itself is streamed.
+type logExporter struct {
+	tw       *tar.Writer
+	dir      string // top-level directory inside the archive
+	created  time.Time
+	redact   *RedactSession
+	manifest ExportManifest
+}
+
+// add spools the output of write into a temporary file on the secure mount
+// and appends it to the archive as name. Errors from write are recorded in the manifest and the
+// partial output is kept; the returned error is only set when the archive
+// itself can no longer be written.
+func (x *logExporter) add(name string, write func(w io.Writer) error) error {
+	spool, err := os.CreateTemp(FsMountPath, ".logexport-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	h := sha256.New()
+	bw := bufio.NewWriter(io.MultiWriter(spool, h))
+	f := ExportFile{Name: name}
+	if err := write(bw); err != nil {
+		f.Error = err.Error()
+		log.Printf("Log export: %s: %v", name, err)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if f.Size, err = spool.Seek(0, io.SeekCurrent); err != nil {
+		return err
+	}
+	f.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := x.writeHeader(name, f.Size); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(x.tw, spool, f.Size); err != nil {
+		return err
+	}
+	x.manifest.Files = append(x.manifest.Files, f)
+	return nil
+}
+
+func (x *logExporter) writeHeader(name string, size int64) error {
+	return x.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     x.dir + "/" + name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  x.created,
+	})
+}
+
+// finish appends manifest.json.
+func (x *logExporter) finish() error {
+	x.manifest.Redactions = x.redact.Count
+	data, err := json.MarshalIndent(x.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := x.writeHeader("manifest.json", int64(len(data))); err != nil {
+		return err
+	}
+	_, err = x.tw.Write(data)
+	return err
+}
+
+// writeEntries returns a member writer that redacts, filters and formats
+// the entries produced by source.
+func (x *logExporter) writeEntries(f LogFilter, asJSON bool, source func(emit func(LogEntry) error) error) func(io.Writer) error {
+	return func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		return source(func(e LogEntry) error {
+			x.redact.Entry(&e)
+			if blobMessageRegex.MatchString(e.Message) || !f.Match(e) {
+				return nil
+			}
+			if asJSON {
+				return enc.Encode(e)
+			}
+			_, err := io.WriteString(w, e.PreciseText()+"\n")
+			return err
+		})
+	}
+}
+
+// MakeLogsExportHandler implements /logs/export: a tar.gz diagnostic snapshot
+// generated on the fly and streamed to the client. It contains
+//
+// - journal/<unit>.log → the whole journal of every configured unit
+// - containers/<name>.log → the complete logs of every container (secure mode)
+// - containers/<name>.inspect.json → the container's inspect document, environment values redacted
+// - status.json, resources.json → the /status and /resources responses
+// - manifest.json → size and SHA-256 of every member
+//
+// - ?since=, ?until=, ?grep=, ?level= → same filters as /logs
+// - ?format=ndjson → log members as NDJSON instead of text
+func MakeLogsExportHandler(secure bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+		filter, err := parseLogFilter(r.URL.Query())
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid filter", err.Error())
+			return
+		}
+		format := r.URL.Query().Get("format")
+		switch format {
+		case "", LogFormatText, LogFormatNDJSON:
+		default:
+			respondWithError(w, http.StatusBadRequest, "Invalid format", "format must be text or ndjson")
+			return
+		}
+		asJSON := format == LogFormatNDJSON
+		ext := ".log"
+		if asJSON {
+			ext = ".ndjson"
+		}
+
+		// Members are spooled on the secure mount, never to an unencrypted /tmp.
+		if fi, err := os.Stat(FsMountPath); err != nil || !fi.IsDir() {
+			respondWithError(w, http.StatusServiceUnavailable, "Secure mount unavailable",
+				fmt.Sprintf("Cannot spool the export under %s", FsMountPath))
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		// Large exports outlive the server's WriteTimeout.
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		hostName, _ := os.Hostname()
+		now := time.Now().UTC()
+		dir := fmt.Sprintf("secretvm-logs-%s-%s", hostName, now.Format("20060102T150405Z"))
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", dir+".tar.gz"))
+		w.WriteHeader(http.StatusOK)
+
+		gz := gzip.NewWriter(w)
+		x := &logExporter{
+			tw:      tar.NewWriter(gz),
+			dir:     dir,
+			created: now,
+			redact:  LogRedactor.NewSession(),
+			manifest: ExportManifest{
+				Created: now,
+				Host:    hostName,
+				Version: Version,
+				Query:   r.URL.RawQuery,
+			},
+		}
+		if err := exportMembers(r.Context(), x, secure, filter, asJSON, ext, hostName); err != nil {
+			// Headers are already sent; the truncated archive tells the client.
+			log.Printf("Log export aborted: %v", err)
+			return
+		}
+		if err := x.finish(); err != nil {
+			log.Printf("Log export aborted: %v", err)
+			return
+		}
+		if err := x.tw.Close(); err != nil {
+			log.Printf("Log export aborted: %v", err)
+			return
+		}
+		if err := gz.Close(); err != nil {
+			log.Printf("Log export aborted: %v", err)
+		}
+	}
+}
+
+// exportMembers adds every member except the manifest.
+func exportMembers(ctx context.Context, x *logExporter, secure bool, f LogFilter, asJSON bool, ext, hostName string) error {
+	for _, unit := range LogUnits {
+		q := JournalQuery{Units: []string{unit}, Lines: -1, Since: f.Since, Until: f.Until}
+		err := x.add("journal/"+unit+ext, x.writeEntries(f, asJSON, func(emit func(LogEntry) error) error {
+			var emitErr error
+			err := streamJournal(ctx, q, func(e LogEntry) bool {
+				emitErr = emit(e)
+				return emitErr == nil
+			})
+			if emitErr != nil {
+				return emitErr
+			}
+			return err
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	if secure {
+		containers, err := Docker.ListContainers(ctx, true)
+		if err != nil {
+			log.Printf("Log export: %v", err)
+		}
+		for _, c := range containers {
+			name := c.Name()
+			err := x.add("containers/"+name+".inspect.json", func(w io.Writer) error {
+				return writeExportInspect(ctx, w, name)
+			})
+			if err != nil {
+				return err
+			}
+			err = x.add("containers/"+name+ext, x.writeEntries(f, asJSON, func(emit func(LogEntry) error) error {
+				return streamContainerLogs(ctx, name, f, hostName, emit)
+			}))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := x.add("status.json", func(w io.Writer) error {
+		response, failed := statusResponse()
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			return err
+		}
+		if failed {
+			return fmt.Errorf("orchestrator status %v", response["status"])
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return x.add("resources.json", func(w io.Writer) error {
+		stats, ok := latestResources(false)
+		if !ok {
+			return fmt.Errorf("no resource sample yet")
+		}
+		return json.NewEncoder(w).Encode(stats)
+	})
+}
+
+// streamContainerLogs passes the complete logs of a container to emit.
+func streamContainerLogs(ctx context.Context, name string, f LogFilter, hostName string, emit func(LogEntry) error) error {
+	info, err := Docker.InspectContainer(ctx, name)
+	if err != nil {
+		return err
+	}
+	rc, err := Docker.ContainerLogs(ctx, name, DockerLogsOptions{Tail: -1, Since: f.Since, Until: f.Until})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return newDockerLogReader(name, info.State.Pid, hostName, emit).Read(rc, info.Config.Tty)
+}
+
+// writeExportInspect writes a container's inspect document with the values
+// of its environment variables redacted, as they commonly hold credentials.
+func writeExportInspect(ctx context.Context, w io.Writer, name string) error {
+	raw, err := Docker.InspectContainerRaw(ctx, name)
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	if cfg, ok := doc["Config"].(map[string]interface{}); ok {
+		if env, ok := cfg["Env"].([]interface{}); ok {
+			for i, v := range env {
+				if s, ok := v.(string); ok {
+					if k, _, found := strings.Cut(s, "="); found {
+						env[i] = k + "=" + redactedMark
+					}
+				}
+			}
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
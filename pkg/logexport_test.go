@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogsExportArchive(t *testing.T) {
+	startFakeDocker(t, fakeDockerMux(t))
+	prevUnits, prevMount := LogUnits, FsMountPath
+	LogUnits = nil
+	t.Cleanup(func() { LogUnits, FsMountPath = prevUnits, prevMount })
+
+	FsMountPath = filepath.Join(t.TempDir(), "missing")
+	rec := httptest.NewRecorder()
+	MakeLogsExportHandler(true)(rec, httptest.NewRequest(http.MethodGet, "/logs/export", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without the secure mount: status %d", rec.Code)
+	}
+
+	FsMountPath = t.TempDir()
+	rec = httptest.NewRecorder()
+	MakeLogsExportHandler(true)(rec, httptest.NewRequest(http.MethodGet, "/logs/export", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := map[string][]byte{}
+	var order []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		_, name, _ := strings.Cut(hdr.Name, "/")
+		members[name] = data
+		order = append(order, name)
+	}
+	if order[len(order)-1] != "manifest.json" {
+		t.Errorf("manifest is not the last member: %v", order)
+	}
+
+	var manifest ExportManifest
+	if err := json.Unmarshal(members["manifest.json"], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != len(members)-1 {
+		t.Errorf("manifest lists %d files, archive has %d", len(manifest.Files), len(members)-1)
+	}
+	for _, f := range manifest.Files {
+		sum := sha256.Sum256(members[f.Name])
+		if hex.EncodeToString(sum[:]) != f.SHA256 || int64(len(members[f.Name])) != f.Size {
+			t.Errorf("%s: size or hash mismatch", f.Name)
+		}
+	}
+
+	if log := string(members["containers/web.log"]); !strings.Contains(log, "listening on :8080") || !strings.Contains(log, "2025-08-27T07:58:25Z") {
+		t.Errorf("web.log:\n%s", log)
+	}
+	inspect := string(members["containers/web.inspect.json"])
+	if strings.Contains(inspect, "hunter2") || !strings.Contains(inspect, "DB_PASSWORD=[REDACTED]") {
+		t.Errorf("environment not redacted:\n%s", inspect)
+	}
+	if _, ok := members["status.json"]; !ok {
+		t.Error("missing status.json")
+	}
+	if spooled, _ := os.ReadDir(FsMountPath); len(spooled) != 0 {
+		t.Errorf("spool files left behind: %v", spooled)
+	}
+}
@@ -0,0 +1,224 @@
+// pkg/logs.go
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogEntry is a single log record from a systemd unit or a docker container.
+type LogEntry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Host       string    `json:"host,omitempty"`
+	Source     string    `json:"source"`               // systemd unit (syslog identifier if none), or container name
+	Identifier string    `json:"identifier,omitempty"` // syslog identifier of journal entries
+	Stream     string    `json:"stream,omitempty"`     // stdout or stderr for containers
+	PID        int       `json:"pid,omitempty"`
+	Level      string    `json:"level,omitempty"`
+	Message    string    `json:"message"`
+	Cursor     string    `json:"cursor,omitempty"` // journal cursor, usable with ?cursor= / ?after=
+}
+
+// Text renders the entry in journalctl short format:
+// "Aug 27 07:58:24 <host> <source>[pid]: <message>"
+func (e LogEntry) Text() string {
+	return e.format(e.Timestamp.Local().Format("Jan 02 15:04:05"))
+}
+
+// PreciseText is Text with a full RFC3339 UTC timestamp, as in
+// journalctl's short-iso-precise format.
+func (e LogEntry) PreciseText() string {
+	return e.format(e.Timestamp.UTC().Format(time.RFC3339Nano))
+}
+
+func (e LogEntry) format(ts string) string {
+	// Like journalctl, show the syslog identifier rather than the unit.
+	src := e.Source
+	if e.Identifier != "" {
+		src = e.Identifier
+	}
+	if e.PID > 0 {
+		src = fmt.Sprintf("%s[%d]", src, e.PID)
+	}
+	return fmt.Sprintf("%s %s %s: %s", ts, e.Host, src, e.Message)
+}
+
+// Normalized log levels, from least to most severe.
+const (
+	LevelDebug    = "debug"
+	LevelInfo     = "info"
+	LevelWarning  = "warning"
+	LevelError    = "error"
+	LevelCritical = "critical"
+)
+
+var levelRanks = map[string]int{
+	LevelDebug:    1,
+	LevelInfo:     2,
+	LevelWarning:  3,
+	LevelError:    4,
+	LevelCritical: 5,
+}
+
+// levelAliases maps the spellings seen in application logs to normalized levels.
+var levelAliases = map[string]string{
+	"trace": LevelDebug, "debug": LevelDebug, "dbg": LevelDebug,
+	"info": LevelInfo, "notice": LevelInfo,
+	"warn": LevelWarning, "warning": LevelWarning,
+	"err": LevelError, "error": LevelError,
+	"crit": LevelCritical, "critical": LevelCritical, "fatal": LevelCritical,
+	"panic": LevelCritical, "alert": LevelCritical, "emerg": LevelCritical,
+}
+
+// levelRegex finds a level keyword near the start of a message, e.g.
+// "ERROR ...", "[warn] ...", "level=info ...", "2025/01/01 12:00:00 [FATAL] ...".
+var levelRegex = regexp.MustCompile(`(?i)^.{0,48}?\b(?:level=)?"?(trace|debug|dbg|info|notice|warn|warning|err|error|crit|critical|fatal|panic|alert|emerg)\b`)
+
+// normalizeLevel returns the normalized level for name, or "" if unknown.
+func normalizeLevel(name string) string {
+	return levelAliases[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// detectLevel guesses the level of a free-form message from a leading keyword.
+func detectLevel(msg string) string {
+	if m := levelRegex.FindStringSubmatch(msg); m != nil {
+		return normalizeLevel(m[1])
+	}
+	return ""
+}
+
+// blobMessageRegex matches the placeholder systemd writes when a log entry
+// was a binary blob instead of a readable string, e.g. "[56B blob data]".
+var blobMessageRegex = regexp.MustCompile(`^\[[0-9]+B blob data\]$`)
+
+// LogFilter holds the server-side filters of /logs.
+type LogFilter struct {
+	Since    time.Time
+	Until    time.Time
+	Grep     *regexp.Regexp
+	MinLevel string
+}
+
+// parseLogFilter reads since, until, grep and level from the query.
+// Times are RFC3339, Unix seconds, or a duration meaning "ago" (e.g. 15m).
+func parseLogFilter(q url.Values) (LogFilter, error) {
+	var f LogFilter
+	now := time.Now()
+	var err error
+	if s := q.Get("since"); s != "" {
+		if f.Since, err = parseTimeParam(s, now); err != nil {
+			return f, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if s := q.Get("until"); s != "" {
+		if f.Until, err = parseTimeParam(s, now); err != nil {
+			return f, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	if s := q.Get("grep"); s != "" {
+		if f.Grep, err = regexp.Compile(s); err != nil {
+			return f, fmt.Errorf("invalid grep: %w", err)
+		}
+	}
+	if s := q.Get("level"); s != "" {
+		if f.MinLevel = normalizeLevel(s); f.MinLevel == "" {
+			return f, fmt.Errorf("invalid level %q (want debug, info, warning, error or critical)", s)
+		}
+	}
+	return f, nil
+}
+
+// parseTimeParam accepts RFC3339, Unix seconds, or a Go duration relative to now.
+func parseTimeParam(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not RFC3339, Unix seconds or a duration", s)
+}
+
+// Match reports whether e passes the filter. Entries without a detectable
+// level are treated as info.
+func (f LogFilter) Match(e LogEntry) bool {
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Timestamp.After(f.Until) {
+		return false
+	}
+	if f.MinLevel != "" {
+		level := e.Level
+		if level == "" {
+			level = LevelInfo
+		}
+		if levelRanks[level] < levelRanks[f.MinLevel] {
+			return false
+		}
+	}
+	if f.Grep != nil && !f.Grep.MatchString(e.Message) {
+		return false
+	}
+	return true
+}
+
+// filterEntries returns the entries passing f, dropping blob placeholders.
+func filterEntries(entries []LogEntry, f LogFilter) []LogEntry {
+	out := entries[:0]
+	for _, e := range entries {
+		if blobMessageRegex.MatchString(e.Message) || !f.Match(e) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// Output formats of /logs.
+const (
+	LogFormatText   = "text"
+	LogFormatJSON   = "json"
+	LogFormatNDJSON = "ndjson"
+)
+
+// writeLogsResponse writes entries as journalctl-formatted text, a JSON
+// array, or newline-delimited JSON streamed entry by entry.
+func writeLogsResponse(w http.ResponseWriter, format string, entries []LogEntry) {
+	switch format {
+	case LogFormatJSON:
+		if entries == nil {
+			entries = []LogEntry{}
+		}
+		respondWithJSON(w, http.StatusOK, entries)
+	case LogFormatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		}
+	default:
+		var b strings.Builder
+		for _, e := range entries {
+			b.WriteString(e.Text())
+			b.WriteByte('\n')
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(b.String()))
+	}
+}
@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDetectLevel(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{"ERROR something broke", LevelError},
+		{"[warn] disk almost full", LevelWarning},
+		{`time="2025-08-27T07:58:24Z" level=info msg="started"`, LevelInfo},
+		{"2025/08/27 07:58:24 [FATAL] cannot bind", LevelCritical},
+		{"no information here", ""},
+		{"plain message", ""},
+	}
+	for _, tc := range tests {
+		if got := detectLevel(tc.msg); got != tc.want {
+			t.Errorf("detectLevel(%q) = %q, want %q", tc.msg, got, tc.want)
+		}
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	base := time.Date(2025, 8, 27, 8, 0, 0, 0, time.UTC)
+	q := url.Values{
+		"since": {base.Add(-time.Minute).Format(time.RFC3339)},
+		"until": {base.Add(time.Minute).Format(time.RFC3339)},
+		"grep":  {"quote|jwt"},
+		"level": {"warn"},
+	}
+	f, err := parseLogFilter(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		e    LogEntry
+		want bool
+	}{
+		{"match", LogEntry{Timestamp: base, Level: LevelError, Message: "bad quote"}, true},
+		{"too old", LogEntry{Timestamp: base.Add(-time.Hour), Level: LevelError, Message: "bad quote"}, false},
+		{"too new", LogEntry{Timestamp: base.Add(time.Hour), Level: LevelError, Message: "bad quote"}, false},
+		{"low level", LogEntry{Timestamp: base, Level: LevelInfo, Message: "quote ok"}, false},
+		{"unknown level is info", LogEntry{Timestamp: base, Message: "quote ok"}, false},
+		{"no grep match", LogEntry{Timestamp: base, Level: LevelError, Message: "other"}, false},
+	}
+	for _, tc := range tests {
+		if got := f.Match(tc.e); got != tc.want {
+			t.Errorf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := parseLogFilter(url.Values{"grep": {"("}}); err == nil {
+		t.Error("invalid regex accepted")
+	}
+	if _, err := parseLogFilter(url.Values{"level": {"loud"}}); err == nil {
+		t.Error("invalid level accepted")
+	}
+}
@@ -0,0 +1,64 @@
+package logsig
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := url.Values{"service": {"secretvm"}, "lines": {"10"}, "token": {"secret"}, "sign": {"true"}}
+	body := []byte("Aug 27 07:58:24 vm app: hello\n")
+
+	h := http.Header{}
+	Sign(h, priv, "/logs", q, body, time.Date(2025, 8, 27, 8, 0, 0, 0, time.UTC))
+	if got := h.Get(HeaderParams); got != "lines=10&service=secretvm" {
+		t.Errorf("params = %q", got)
+	}
+	if h.Get(HeaderKey) != KeyID(pub) {
+		t.Error("key id mismatch")
+	}
+	if err := Verify(h, pub, body); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify(h, pub, []byte("tampered")); err == nil {
+		t.Error("tampered body verified")
+	}
+	h2 := h.Clone()
+	h2.Set(HeaderParams, "lines=10&service=web")
+	if err := Verify(h2, pub, body); err == nil {
+		t.Error("tampered params verified")
+	}
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	if err := Verify(h, other, body); err == nil {
+		t.Error("verified with another key")
+	}
+}
+
+func TestParseKeysPEM(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+
+	gotPriv, err := ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}))
+	if err != nil || !gotPriv.Equal(priv) {
+		t.Fatalf("private key: %v", err)
+	}
+	gotPub, err := ParsePublicKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	if err != nil || !gotPub.Equal(pub) {
+		t.Fatalf("public key: %v", err)
+	}
+	if _, err := ParsePrivateKeyPEM([]byte("not pem")); err == nil {
+		t.Error("garbage accepted")
+	}
+}
@@ -0,0 +1,48 @@
+// pkg/logsigning.go
+package pkg
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net/http"
+	"os"
+	"time"
+
+	"secret-vm-attest-rest-server/pkg/logsig"
+)
+
+// loadLogSigningKey reads the VM's ed25519 private key, the counterpart of
+// the key served at /publickey_ed25519. It is read per request so a key
+// provisioned after startup is picked up.
+func loadLogSigningKey() (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(PrivateKeyEd25519Path)
+	if err != nil {
+		return nil, err
+	}
+	return logsig.ParsePrivateKeyPEM(data)
+}
+
+// bufferedResponse collects a response so it can be signed before it is sent.
+type bufferedResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header), code: http.StatusOK}
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(code int)        { b.code = code }
+
+// signAndSend signs the buffered body for r and writes it to w.
+func (b *bufferedResponse) signAndSend(w http.ResponseWriter, r *http.Request, key ed25519.PrivateKey) {
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	logsig.Sign(w.Header(), key, r.URL.Path, r.URL.Query(), b.body.Bytes(), time.Now())
+	w.WriteHeader(b.code)
+	_, _ = w.Write(b.body.Bytes())
+}
@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"secret-vm-attest-rest-server/pkg/logsig"
+)
+
+func TestLogsSigned(t *testing.T) {
+	prevStore, prevKey := LogHistory, PrivateKeyEd25519Path
+	t.Cleanup(func() { LogHistory, PrivateKeyEd25519Path = prevStore, prevKey })
+
+	s, err := NewLogStore(t.TempDir(), 1<<20, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	LogHistory = s
+	s.Append(journalSource, LogEntry{Timestamp: storeEntry(1).Timestamp, Source: "secret-vm-supervisor", Message: "started"})
+
+	const target = "/logs?history=true&format=json&token=secret&sign=true"
+	PrivateKeyEd25519Path = filepath.Join(t.TempDir(), "missing.pem")
+	rec := httptest.NewRecorder()
+	MakeVMLogsHandler(true)(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a key: status %d", rec.Code)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	PrivateKeyEd25519Path = filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(PrivateKeyEd25519Path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = httptest.NewRecorder()
+	MakeVMLogsHandler(true)(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte("started")) {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	h, body := rec.Header(), rec.Body.Bytes()
+	if err := logsig.Verify(h, pub, body); err != nil {
+		t.Fatal(err)
+	}
+	if h.Get(logsig.HeaderPath) != "/logs" || h.Get(logsig.HeaderParams) != "format=json&history=true" ||
+		h.Get(logsig.HeaderKey) != logsig.KeyID(pub) {
+		t.Errorf("signature headers: %v", h)
+	}
+	// The signature covers the context line, path, parameters, time and body.
+	msg := logsig.Message(h.Get(logsig.HeaderPath), h.Get(logsig.HeaderParams), h.Get(logsig.HeaderTimestamp), body)
+	sig, _ := base64.StdEncoding.DecodeString(h.Get(logsig.HeaderSignature))
+	if !bytes.HasPrefix(msg, []byte(logsig.Context+"\n/logs\n")) || !ed25519.Verify(pub, msg, sig) {
+		t.Errorf("signed message %q", msg)
+	}
+
+	tampered := bytes.Replace(body, []byte("started"), []byte("stopped"), 1)
+	if err := logsig.Verify(h, pub, tampered); err == nil {
+		t.Error("tampered body verified")
+	}
+	h.Set(logsig.HeaderParams, "format=json&history=true&service=web")
+	if err := logsig.Verify(h, pub, body); err == nil {
+		t.Error("tampered parameters verified")
+	}
+}
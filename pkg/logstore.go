@@ -0,0 +1,354 @@
+// pkg/logstore.go
+package pkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentExt is the extension of log store segment files.
+const segmentExt = ".ndjson"
+
+// storeSourceRegex restricts source names, which become directory names.
+var storeSourceRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// LogStore keeps log entries on disk, one directory per source (a container
+// name, or "secretvm" for the journal). Each directory holds NDJSON segments
+// named after the Unix nanosecond time they were started; when the store
+// grows beyond its size limit the oldest segments of all sources are deleted.
+// The segments and their total size are indexed in memory when the store is
+// opened, so appending never rescans the directory.
+type LogStore struct {
+	mu          sync.Mutex
+	dir         string
+	segmentSize int64
+	maxSize     int64
+	total       int64
+	segs        []*segmentInfo // all sources, oldest first
+	active      map[string]*storeSegment
+}
+
+// storeSegment is the segment a source currently appends to.
+type storeSegment struct {
+	f    *os.File
+	info *segmentInfo
+}
+
+// NewLogStore opens or creates a store in dir.
+func NewLogStore(dir string, segmentSize, maxSize int64) (*LogStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &LogStore{dir: dir, segmentSize: segmentSize, maxSize: maxSize, active: make(map[string]*storeSegment)}
+	segs, err := s.allSegments()
+	if err != nil {
+		return nil, err
+	}
+	for i := range segs {
+		s.segs = append(s.segs, &segs[i])
+		s.total += segs[i].size
+	}
+	return s, nil
+}
+
+// segmentInfo is a segment file on disk.
+type segmentInfo struct {
+	source  string
+	path    string
+	started int64 // Unix nanoseconds from the file name
+	size    int64
+}
+
+// segments returns the segments of source, oldest first.
+func (s *LogStore) segments(source string) ([]segmentInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, source))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []segmentInfo
+	for _, de := range entries {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		started, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, segmentInfo{source: source, path: filepath.Join(s.dir, source, name), started: started, size: info.Size()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].started < out[j].started })
+	return out, nil
+}
+
+// allSegments returns the segments of every source, oldest first.
+func (s *LogStore) allSegments() ([]segmentInfo, error) {
+	sources, err := s.Sources()
+	if err != nil {
+		return nil, err
+	}
+	var all []segmentInfo
+	for _, src := range sources {
+		segs, err := s.segments(src)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, segs...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].started < all[j].started })
+	return all, nil
+}
+
+// Sources returns the names of all sources with stored logs.
+func (s *LogStore) Sources() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, de := range entries {
+		if de.IsDir() && storeSourceRegex.MatchString(de.Name()) {
+			out = append(out, de.Name())
+		}
+	}
+	return out, nil
+}
+
+// Append stores e under source.
+func (s *LogStore) Append(source string, e LogEntry) error {
+	if !storeSourceRegex.MatchString(source) {
+		return fmt.Errorf("invalid log source name %q", source)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seg, err := s.segmentFor(source, int64(len(line)))
+	if err != nil {
+		return err
+	}
+	n, err := seg.f.Write(line)
+	seg.info.size += int64(n)
+	s.total += int64(n)
+	if err != nil {
+		return err
+	}
+	if s.total > s.maxSize {
+		s.enforceLimit()
+	}
+	return nil
+}
+
+// segmentFor returns the segment to append n bytes to, rotating when the
+// current one is full. It is called with s.mu held.
+func (s *LogStore) segmentFor(source string, n int64) (*storeSegment, error) {
+	seg := s.active[source]
+	if seg == nil {
+		// Continue the newest segment left by a previous run if it has room.
+		if last := s.newest(source); last != nil && last.size+n <= s.segmentSize {
+			f, err := os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0600)
+			if err == nil {
+				seg = &storeSegment{f: f, info: last}
+				s.active[source] = seg
+				return seg, nil
+			}
+		}
+	} else if seg.info.size == 0 || seg.info.size+n <= s.segmentSize {
+		return seg, nil
+	} else {
+		seg.f.Close()
+		delete(s.active, source)
+	}
+
+	dir := filepath.Join(s.dir, source)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	started := time.Now().UnixNano()
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", started, segmentExt))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	info := &segmentInfo{source: source, path: path, started: started}
+	s.segs = append(s.segs, info)
+	seg = &storeSegment{f: f, info: info}
+	s.active[source] = seg
+	return seg, nil
+}
+
+// newest returns the indexed segment source appended to last, or nil. It is
+// called with s.mu held.
+func (s *LogStore) newest(source string) *segmentInfo {
+	for i := len(s.segs) - 1; i >= 0; i-- {
+		if s.segs[i].source == source {
+			return s.segs[i]
+		}
+	}
+	return nil
+}
+
+// enforceLimit deletes the oldest segments until the store fits maxSize. An
+// active segment that is the oldest one left is rotated first, so a quiet
+// source cannot pin its segment forever; the segment just written to is
+// kept when it is the only one. It is called with s.mu held.
+func (s *LogStore) enforceLimit() {
+	for s.total > s.maxSize && len(s.segs) > 1 {
+		oldest := s.segs[0]
+		if seg := s.active[oldest.source]; seg != nil && seg.info == oldest {
+			seg.f.Close()
+			delete(s.active, oldest.source)
+		}
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return // retried on the next append
+		}
+		s.total -= oldest.size
+		s.segs[0] = nil
+		s.segs = s.segs[1:]
+	}
+}
+
+// Read calls fn with the entries of source in the order they were stored,
+// starting with the segment that may contain since, until fn returns false.
+func (s *LogStore) Read(source string, since time.Time, fn func(LogEntry) bool) error {
+	if !storeSourceRegex.MatchString(source) {
+		return fmt.Errorf("invalid log source name %q", source)
+	}
+	segs, err := s.segments(source)
+	if err != nil {
+		return err
+	}
+	for i, seg := range segs {
+		// Entries are stored no later than they happen, so a segment started
+		// before since holds nothing newer once its successor started too.
+		if !since.IsZero() && i+1 < len(segs) && segs[i+1].started < since.UnixNano() {
+			continue
+		}
+		more, err := readSegment(seg.path, fn)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+// readSegment calls fn for each entry of a segment file; it reports false
+// when fn stopped the reading.
+func readSegment(path string, fn func(LogEntry) bool) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil // removed by rotation meanwhile
+		}
+		return false, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 2*maxLogEntryBytes)
+	for sc.Scan() {
+		var e LogEntry
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue // a line still being written
+		}
+		if !fn(e) {
+			return false, nil
+		}
+	}
+	return true, sc.Err()
+}
+
+// Last returns the most recently stored entry of source.
+func (s *LogStore) Last(source string) (LogEntry, bool) {
+	segs, err := s.segments(source)
+	if err != nil || len(segs) == 0 {
+		return LogEntry{}, false
+	}
+	for i := len(segs) - 1; i >= 0; i-- {
+		var last LogEntry
+		found := false
+		_, _ = readSegment(segs[i].path, func(e LogEntry) bool {
+			last, found = e, true
+			return true
+		})
+		if found {
+			return last, true
+		}
+	}
+	return LogEntry{}, false
+}
+
+// Tail returns the last lines entries of source between since and until.
+// Segments are read newest first, and reading stops once lines entries are
+// collected or the remaining segments cannot hold anything after since.
+func (s *LogStore) Tail(source string, lines int, since, until time.Time) ([]LogEntry, error) {
+	if !storeSourceRegex.MatchString(source) {
+		return nil, fmt.Errorf("invalid log source name %q", source)
+	}
+	segs, err := s.segments(source)
+	if err != nil {
+		return nil, err
+	}
+	var out []LogEntry
+	for i := len(segs) - 1; i >= 0 && len(out) < lines; i-- {
+		// See Read: older segments hold nothing newer than since.
+		if !since.IsZero() && i+1 < len(segs) && segs[i+1].started < since.UnixNano() {
+			break
+		}
+		var ring []LogEntry
+		_, err := readSegment(segs[i].path, func(e LogEntry) bool {
+			if !since.IsZero() && e.Timestamp.Before(since) {
+				return true
+			}
+			if !until.IsZero() && e.Timestamp.After(until) {
+				return true
+			}
+			ring = append(ring, e)
+			if len(ring) > 2*lines {
+				ring = append(ring[:0], ring[len(ring)-lines:]...)
+			}
+			return true
+		})
+		if err != nil {
+			return out, err
+		}
+		if need := lines - len(out); len(ring) > need {
+			ring = ring[len(ring)-need:]
+		}
+		out = append(ring, out...)
+	}
+	return out, nil
+}
+
+// Close closes the segments being appended to.
+func (s *LogStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for source, seg := range s.active {
+		seg.f.Close()
+		delete(s.active, source)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func storeEntry(i int) LogEntry {
+	return LogEntry{
+		Timestamp: time.Date(2025, 8, 27, 7, 0, i, 0, time.UTC),
+		Source:    "web",
+		Message:   fmt.Sprintf("line %02d", i),
+	}
+}
+
+func storeSize(t *testing.T, dir string) int64 {
+	t.Helper()
+	var total int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+func TestLogStoreRotation(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLogStore(dir, 300, 1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "quiet" keeps its only segment active; it is rotated out once it is
+	// the oldest one left.
+	if err := s.Append("quiet", storeEntry(0)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := s.Append("web", storeEntry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Append("../etc", storeEntry(0)); err == nil {
+		t.Error("source with path separators accepted")
+	}
+
+	if size := storeSize(t, dir); size > 1200 {
+		t.Errorf("store holds %d bytes, limit 1200", size)
+	}
+	segs, _ := s.segments("web")
+	if len(segs) < 2 {
+		t.Errorf("expected rotation, got %d segments", len(segs))
+	}
+	if quiet, _ := s.segments("quiet"); len(quiet) != 0 {
+		t.Errorf("quiet source still holds %d segments", len(quiet))
+	}
+	if err := s.Append("quiet", storeEntry(1)); err != nil {
+		t.Fatal(err)
+	}
+	if last, ok := s.Last("quiet"); !ok || last.Message != "line 01" {
+		t.Errorf("quiet after rotation = %+v, %v", last, ok)
+	}
+
+	tail, err := s.Tail("web", 3, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tail) != 3 || tail[0].Message != "line 47" || tail[2].Message != "line 49" {
+		t.Errorf("tail = %+v", tail)
+	}
+	tail, _ = s.Tail("web", 100, time.Time{}, storeEntry(45).Timestamp)
+	if len(tail) == 0 || tail[len(tail)-1].Message != "line 45" {
+		t.Errorf("tail until line 45 = %+v", tail)
+	}
+	s.Close()
+
+	// A reopened store continues where the previous one stopped.
+	s, err = NewLogStore(dir, 300, 1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if last, ok := s.Last("web"); !ok || last.Message != "line 49" {
+		t.Errorf("last = %+v, %v", last, ok)
+	}
+	if err := s.Append("web", storeEntry(50)); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := s.Last("web"); last.Message != "line 50" {
+		t.Errorf("last after reopen = %+v", last)
+	}
+}
+
+func TestLogStoreTailReadsNewestSegments(t *testing.T) {
+	s, err := NewLogStore(t.TempDir(), 300, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := 0; i < 30; i++ {
+		if err := s.Append("web", storeEntry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segs, _ := s.segments("web")
+	if len(segs) < 4 {
+		t.Fatalf("expected several segments, got %d", len(segs))
+	}
+	// A line too long to scan makes reading the oldest segment fail, so
+	// the tail must come from the newest segments alone.
+	if err := os.WriteFile(segs[0].path, make([]byte, 2*maxLogEntryBytes+1), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tail, err := s.Tail("web", 3, time.Time{}, time.Time{})
+	if err != nil || len(tail) != 3 || tail[0].Message != "line 27" || tail[2].Message != "line 29" {
+		t.Errorf("tail = %+v, %v", tail, err)
+	}
+	// A tail spanning segments keeps storage order.
+	tail, err = s.Tail("web", 8, time.Time{}, storeEntry(25).Timestamp)
+	if err != nil || len(tail) != 8 || tail[0].Message != "line 18" || tail[7].Message != "line 25" {
+		t.Errorf("tail until line 25 = %+v, %v", tail, err)
+	}
+	if _, err := s.Tail("web", 100, time.Time{}, time.Time{}); err == nil {
+		t.Error("a tail longer than the store did not reach the oldest segment")
+	}
+}
+
+func TestLogsHistoryHandler(t *testing.T) {
+	prev := LogHistory
+	t.Cleanup(func() { LogHistory = prev })
+
+	LogHistory = nil
+	rec := httptest.NewRecorder()
+	MakeVMLogsHandler(true)(rec, httptest.NewRequest(http.MethodGet, "/logs?history=true", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("disabled store: status %d", rec.Code)
+	}
+
+	s, err := NewLogStore(t.TempDir(), 1<<20, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	LogHistory = s
+	// "removed" no longer exists in docker; its logs are only in the store.
+	for i := 0; i < 5; i++ {
+		e := storeEntry(i)
+		e.Source = "removed"
+		s.Append("removed", e)
+	}
+	s.Append(journalSource, LogEntry{Timestamp: storeEntry(2).Timestamp, Source: "secret-vm-supervisor", Message: "started"})
+
+	rec = httptest.NewRecorder()
+	MakeVMLogsHandler(true)(rec, httptest.NewRequest(http.MethodGet, "/logs?history=true&lines=2&format=json", nil))
+	var entries []LogEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("status %d: %v", rec.Code, err)
+	}
+	if len(entries) != 3 || entries[0].Message != "started" || entries[2].Message != "line 04" {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	rec = httptest.NewRecorder()
+	MakeVMLogsHandler(false)(rec, httptest.NewRequest(http.MethodGet, "/logs?history=true&service=removed", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("container history outside secure mode: status %d", rec.Code)
+	}
+}
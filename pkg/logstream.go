@@ -0,0 +1,281 @@
+// pkg/logstream.go
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// streamReorderDelay is how long an entry is held back so that slightly
+	// late entries from other sources can still be emitted in timestamp order.
+	streamReorderDelay = 500 * time.Millisecond
+	// streamFlushInterval is how often held entries are released.
+	streamFlushInterval = 200 * time.Millisecond
+	// streamKeepAlive is the interval of SSE comments keeping idle connections open.
+	streamKeepAlive = 15 * time.Second
+	// streamMaxPending bounds the reorder buffer; beyond it entries are flushed immediately.
+	streamMaxPending = 10000
+)
+
+// MakeVMLogsStreamHandler implements /logs/stream: it follows the same sources
+// as /logs and pushes new lines as Server-Sent Events.
+//
+// - ?service=secretvm → only system logs (journalctl -o json -f)
+// - ?service={container} → only docker logs for that container
+// - no service param → system logs + all docker logs
+// - ?lines=N → number of backlog lines per source sent first (default 100)
+// - ?grep=, ?level= → same filters as /logs
+// - ?format=json → event data is the JSON entry instead of the journalctl-formatted line
+//
+// Entries are redacted by LogRedactor before they are filtered and sent.
+func MakeVMLogsStreamHandler(secure bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		// The stream outlives the server's WriteTimeout by design.
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Streaming not supported", err.Error())
+			return
+		}
+
+		lines := 100
+		if l := r.URL.Query().Get("lines"); l != "" {
+			if v, err := strconv.Atoi(l); err == nil && v >= 0 {
+				lines = v
+			}
+		}
+		service := r.URL.Query().Get("service")
+		hostName, _ := os.Hostname()
+		asJSON := r.URL.Query().Get("format") == LogFormatJSON
+
+		filter, err := parseLogFilter(r.URL.Query())
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid filter", err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		entries := make(chan LogEntry, 256)
+
+		var sources []func()
+		if service == "" || service == "secretvm" {
+			sources = append(sources, func() { followServicesLogs(ctx, lines, entries) })
+		}
+		if secure && service != "secretvm" {
+			var containers []string
+			if service != "" {
+				if _, err := Docker.InspectContainer(r.Context(), service); IsDockerNotFound(err) {
+					respondWithError(w, http.StatusNotFound, "Service not found", fmt.Sprintf("No container named %q", service))
+					return
+				}
+				containers = []string{service}
+			} else if names, err := listAllDockerContainerNames(); err == nil {
+				containers = names
+			}
+			for _, name := range containers {
+				name := name
+				sources = append(sources, func() { followDockerLogs(ctx, name, lines, hostName, entries) })
+			}
+		}
+		if len(sources) == 0 {
+			respondWithError(w, http.StatusNotFound, "No log sources", "No log sources match the requested service")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		_ = rc.Flush()
+
+		for _, run := range sources {
+			go run()
+		}
+
+		redact := LogRedactor.NewSession()
+		var pending []LogEntry
+		flush := time.NewTicker(streamFlushInterval)
+		defer flush.Stop()
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-entries:
+				redact.Entry(&e)
+				if blobMessageRegex.MatchString(e.Message) || !filter.Match(e) {
+					continue
+				}
+				pending = append(pending, e)
+				if len(pending) < streamMaxPending {
+					continue
+				}
+				pending = emitPending(w, pending, time.Now().Add(time.Hour), asJSON)
+			case <-flush.C:
+				if len(pending) == 0 {
+					continue
+				}
+				pending = emitPending(w, pending, time.Now().Add(-streamReorderDelay), asJSON)
+			case <-keepAlive.C:
+				_, _ = io.WriteString(w, ": keep-alive\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// emitPending writes, in timestamp order, every pending entry not newer than
+// watermark and returns the entries still held back.
+func emitPending(w io.Writer, pending []LogEntry, watermark time.Time, asJSON bool) []LogEntry {
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].Timestamp.Before(pending[j].Timestamp) })
+	n := sort.Search(len(pending), func(i int) bool { return pending[i].Timestamp.After(watermark) })
+	for _, e := range pending[:n] {
+		if asJSON {
+			data, _ := json.Marshal(e)
+			writeSSE(w, "log", string(data))
+			continue
+		}
+		writeSSE(w, "log", e.Text())
+	}
+	return append(pending[:0], pending[n:]...)
+}
+
+// writeSSE writes a single Server-Sent Event; multi-line data is split into
+// several data fields as required by the protocol.
+func writeSSE(w io.Writer, event, data string) {
+	var b strings.Builder
+	b.WriteString("event: ")
+	b.WriteString(event)
+	b.WriteByte('\n')
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: ")
+		b.WriteString(strings.TrimRight(line, "\r"))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	_, _ = io.WriteString(w, b.String())
+}
+
+// followCommand runs cmd until ctx is cancelled and sends every line parsed
+// from its stdout and stderr to out; parse receives the stream name.
+func followCommand(ctx context.Context, cmd *exec.Cmd, parse func(line, stream string) (LogEntry, bool), out chan<- LogEntry) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Printf("Log stream: %v", err)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		log.Printf("Log stream: %v", err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		log.Printf("Log stream: failed to start %s: %v", cmd.Path, err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	scan := func(r io.Reader, stream string) {
+		defer wg.Done()
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			e, ok := parse(sc.Text(), stream)
+			if !ok {
+				continue
+			}
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go scan(stdout, "stdout")
+	go scan(stderr, "stderr")
+	wg.Wait()
+	_ = cmd.Wait()
+}
+
+// followServicesLogs follows the configured systemd units, starting with the last lines entries.
+func followServicesLogs(ctx context.Context, lines int, out chan<- LogEntry) {
+	q := JournalQuery{Units: LogUnits, Lines: lines}
+	cmd := exec.CommandContext(ctx, "journalctl", journalArgs(q, true)...)
+	followCommand(ctx, cmd, func(line, stream string) (LogEntry, bool) {
+		if stream != "stdout" {
+			return LogEntry{}, false
+		}
+		return parseJournalJSONLine([]byte(line))
+	}, out)
+}
+
+// followDockerLogs follows a container's logs, starting with the last lines entries.
+func followDockerLogs(ctx context.Context, container string, lines int, hostName string, out chan<- LogEntry) {
+	err := followContainerLogs(ctx, container, DockerLogsOptions{Follow: true, Tail: lines}, hostName, func(e LogEntry) error {
+		select {
+		case out <- e:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Log stream: %s: %v", container, err)
+	}
+}
+
+// followContainerLogs reads a container's logs with opts and passes every
+// entry to emit until the stream ends or ctx is cancelled. Entries are
+// released once no continuation line followed them for streamFlushInterval.
+func followContainerLogs(ctx context.Context, container string, opts DockerLogsOptions, hostName string, emit func(LogEntry) error) error {
+	info, err := Docker.InspectContainer(ctx, container)
+	if err != nil {
+		return err
+	}
+	rc, err := Docker.ContainerLogs(ctx, container, opts)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	reader := newDockerLogReader(container, info.State.Pid, hostName, emit)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(streamFlushInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				_ = reader.FlushIdle(streamFlushInterval)
+			}
+		}
+	}()
+	return reader.Read(rc, info.Config.Tty)
+}
@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmitPendingOrdersAndHoldsBack(t *testing.T) {
+	base := time.Date(2025, 8, 27, 7, 58, 0, 0, time.UTC)
+	entry := func(offset time.Duration, msg string) LogEntry {
+		return LogEntry{Timestamp: base.Add(offset), Host: "vm", Source: "app", Message: msg}
+	}
+	pending := []LogEntry{
+		entry(2*time.Second, "c"),
+		entry(0, "a"),
+		entry(10*time.Second, "late"),
+		entry(time.Second, "b\nsecond line"),
+	}
+
+	var buf bytes.Buffer
+	rest := emitPending(&buf, pending, base.Add(5*time.Second), false)
+
+	prefix := func(offset time.Duration) string {
+		return "data: " + base.Add(offset).Local().Format("Jan 02 15:04:05") + " vm app: "
+	}
+	want := "event: log\n" + prefix(0) + "a\n\n" +
+		"event: log\n" + prefix(time.Second) + "b\ndata: second line\n\n" +
+		"event: log\n" + prefix(2*time.Second) + "c\n\n"
+	if buf.String() != want {
+		t.Errorf("emitted:\n%q\nwant:\n%q", buf.String(), want)
+	}
+	if len(rest) != 1 || rest[0].Message != "late" {
+		t.Errorf("held back: %+v", rest)
+	}
+}
+
+func TestLogStreamHandler(t *testing.T) {
+	mux := fakeDockerMux(t)
+	startFakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/web/logs" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		line := func(msg string) {
+			w.Write(dockerFrame(1, time.Now().UTC().Format(time.RFC3339Nano)+" "+msg+"\n"))
+			w.(http.Flusher).Flush()
+		}
+		line("keep early")
+		line("drop this")
+		// Outlast the server's write timeout before the next line.
+		time.Sleep(300 * time.Millisecond)
+		line("keep late")
+		<-r.Context().Done()
+	}))
+
+	srv := httptest.NewUnstartedServer(MakeVMLogsStreamHandler(true))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?service=nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown service: status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "?service=web&grep=keep&lines=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var events []string
+	sc := bufio.NewScanner(resp.Body)
+	for len(events) < 2 && sc.Scan() {
+		switch line := sc.Text(); {
+		case line == "event: log", line == "":
+		case strings.HasPrefix(line, "data: "):
+			events = append(events, line)
+		default:
+			t.Errorf("unexpected line %q", line)
+		}
+	}
+	if len(events) != 2 || !strings.HasSuffix(events[0], "]: keep early") || !strings.HasSuffix(events[1], "]: keep late") {
+		t.Errorf("events = %q, scan error %v", events, sc.Err())
+	}
+}
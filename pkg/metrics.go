@@ -0,0 +1,348 @@
+// pkg/metrics.go
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsNamespace prefixes every metric name.
+const metricsNamespace = "secretvm_"
+
+// Histogram buckets in seconds.
+var (
+	requestDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	fetchDurationBuckets   = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+)
+
+// counterVec is a counter family with labels, in the Prometheus text format.
+type counterVec struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	labels []string
+	values map[string]float64 // by encoded label values
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: metricsNamespace + name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// Inc adds one to the counter of the given label values.
+func (c *counterVec) Inc(values ...string) {
+	c.mu.Lock()
+	c.values[labelKey(values)]++
+	c.mu.Unlock()
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedMetricKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, key, "", ""), formatValue(c.values[key]))
+	}
+}
+
+// histogramVec is a histogram family with labels.
+type histogramVec struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		name: metricsNamespace + name, help: help, labels: labels, buckets: buckets,
+		series: make(map[string]*histogram),
+	}
+}
+
+// Observe records v for the given label values.
+func (h *histogramVec) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := labelKey(values)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", formatValue(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, "", ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, "", ""), s.count)
+	}
+}
+
+// gauge is a single sample of a gauge family, collected at scrape time.
+type gauge struct {
+	labels []string // name, value pairs
+	value  float64
+}
+
+// writeGauges writes a gauge family; nothing is written without samples.
+func writeGauges(w io.Writer, name, help string, samples ...gauge) {
+	if len(samples) == 0 {
+		return
+	}
+	name = metricsNamespace + name
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	for _, s := range samples {
+		var names, values []string
+		for i := 0; i+1 < len(s.labels); i += 2 {
+			names = append(names, s.labels[i])
+			values = append(values, s.labels[i+1])
+		}
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(names, labelKey(values), "", ""), formatValue(s.value))
+	}
+}
+
+// labelKey encodes label values as a map key.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func sortedMetricKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders `{a="x",b="y"}` from names and an encoded key, with an
+// optional extra label such as a histogram's "le".
+func formatLabels(names []string, key, extraName, extraValue string) string {
+	var parts []string
+	if len(names) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			if i < len(names) {
+				parts = append(parts, names[i]+`="`+labelEscaper.Replace(v)+`"`)
+			}
+		}
+	}
+	if extraName != "" {
+		parts = append(parts, extraName+`="`+extraValue+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Metrics collected while serving.
+var (
+	httpRequests = newCounterVec("http_requests_total",
+		"HTTP requests by method, route and status code.", "method", "route", "code")
+	httpRequestDuration = newHistogramVec("http_request_duration_seconds",
+		"HTTP request latency by method and route.", requestDurationBuckets, "method", "route")
+	jwtFetches = newCounterVec("jwt_fetch_total",
+		"ITA and Proof of Cloud JWT fetches by result.", "kind", "result")
+	jwtFetchDuration = newHistogramVec("jwt_fetch_duration_seconds",
+		"ITA and Proof of Cloud JWT fetch latency.", fetchDurationBuckets, "kind")
+)
+
+// routeLabelKey holds the *string a route handler fills in with its route,
+// so LoggingMiddleware can label requests by route rather than raw path.
+type routeLabelKey struct{}
+
+// withRouteLabel sets the route label of the request, if it is being recorded.
+func withRouteLabel(r *http.Request, route string) {
+	if p, ok := r.Context().Value(routeLabelKey{}).(*string); ok {
+		*p = route
+	}
+}
+
+// metricMethods are the request methods labelled by name; any other method
+// is labelled "other".
+var metricMethods = []string{http.MethodGet, http.MethodPost, http.MethodOptions, http.MethodHead}
+
+// observeRequest records a served request. Requests matching no route, or
+// using an unusual method, share the "other" label, so unknown paths and
+// made-up methods cannot inflate the number of series.
+func observeRequest(r *http.Request, route string, code int, d time.Duration) {
+	if route == "" {
+		route = "other"
+	}
+	method := r.Method
+	if !slices.Contains(metricMethods, method) {
+		method = "other"
+	}
+	httpRequests.Inc(method, route, strconv.Itoa(code))
+	httpRequestDuration.Observe(d.Seconds(), method, route)
+}
+
+// observeJWTFetch records a JWT fetch started at start; err points at its result.
+func observeJWTFetch(kind string, start time.Time, err *error) {
+	result := "success"
+	if *err != nil {
+		result = "failure"
+	}
+	jwtFetches.Inc(kind, result)
+	jwtFetchDuration.Observe(time.Since(start).Seconds(), kind)
+}
+
+// writeResourceGauges writes the latest sample of the resource sampler.
+func writeResourceGauges(w io.Writer) {
+	stats, _, _, ok := Resources.Latest()
+	if !ok {
+		return
+	}
+	writeGauges(w, "memory_used_bytes", "Memory in use.", gauge{value: float64(stats.memoryUsed)})
+	writeGauges(w, "memory_total_bytes", "Total memory.", gauge{value: float64(stats.memoryTotal)})
+	writeGauges(w, "swap_used_bytes", "Swap in use.", gauge{value: float64(stats.swapUsed)})
+	writeGauges(w, "swap_total_bytes", "Total swap.", gauge{value: float64(stats.swapTotal)})
+	writeDiskGauges(w, stats)
+	writeGauges(w, "cpu_usage_percent", "CPU usage over the latest sampling interval.", gauge{value: stats.CPUPercent})
+	writeGauges(w, "load_average", "System load average.",
+		gauge{[]string{"period", "1m"}, stats.Load1}, gauge{[]string{"period", "5m"}, stats.Load5},
+		gauge{[]string{"period", "15m"}, stats.Load15})
+	writeGauges(w, "network_received_bytes", "Bytes received on all interfaces since boot.", gauge{value: float64(stats.NetRxBytes)})
+	writeGauges(w, "network_transmitted_bytes", "Bytes sent on all interfaces since boot.", gauge{value: float64(stats.NetTxBytes)})
+}
+
+// writeDiskGauges writes space, inode and read-only gauges per reported
+// filesystem, falling back to FsMountPath for samples without disks.
+func writeDiskGauges(w io.Writer, stats ResourceStats) {
+	var used, total, inodesUsed, inodesTotal, readOnly []gauge
+	if len(stats.Disks) == 0 {
+		path := []string{"path", FsMountPath}
+		used, total = []gauge{{path, float64(stats.diskUsed)}}, []gauge{{path, float64(stats.diskTotal)}}
+	}
+	for _, d := range stats.Disks {
+		if d.Error != "" {
+			continue
+		}
+		path := []string{"path", d.Path}
+		used = append(used, gauge{path, float64(d.used)})
+		total = append(total, gauge{path, float64(d.total)})
+		inodesUsed = append(inodesUsed, gauge{path, float64(d.InodesUsed)})
+		inodesTotal = append(inodesTotal, gauge{path, float64(d.InodesTotal)})
+		ro := 0.0
+		if d.ReadOnly {
+			ro = 1
+		}
+		readOnly = append(readOnly, gauge{path, ro})
+	}
+	writeGauges(w, "disk_used_bytes", "Disk space in use.", used...)
+	writeGauges(w, "disk_total_bytes", "Total disk space.", total...)
+	writeGauges(w, "disk_inodes_used", "Inodes in use.", inodesUsed...)
+	writeGauges(w, "disk_inodes_total", "Total inodes.", inodesTotal...)
+	writeGauges(w, "disk_read_only", "Whether the filesystem is mounted read-only.", readOnly...)
+}
+
+// writeAttestationGauges writes the age of every attestation report file.
+func writeAttestationGauges(w io.Writer) {
+	var samples []gauge
+	for _, f := range attestationFiles() {
+		if fi, err := os.Stat(filepath.Join(ReportDir, f.name)); err == nil {
+			samples = append(samples, gauge{[]string{"type", f.kind}, time.Since(fi.ModTime()).Seconds()})
+		}
+	}
+	writeGauges(w, "attestation_file_age_seconds", "Time since an attestation report file was written.", samples...)
+}
+
+// writeContainerGauges writes one state sample per container; docker_up is
+// 0 when the Docker Engine API cannot be reached.
+func writeContainerGauges(ctx context.Context, w io.Writer) {
+	containers, err := Docker.ListContainers(ctx, true)
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	writeGauges(w, "docker_up", "Whether the Docker Engine API is reachable.", gauge{value: up})
+	var samples []gauge
+	for _, c := range containers {
+		samples = append(samples, gauge{[]string{"container", c.Name(), "state", c.State}, 1})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].labels[1] < samples[j].labels[1] })
+	writeGauges(w, "container_state", "Current state of each container (always 1).", samples...)
+}
+
+// writeTLSGauges writes the expiry of the certificates in use.
+func writeTLSGauges(w io.Writer) {
+	var samples []gauge
+	if ServerCert != nil {
+		samples = append(samples, gauge{[]string{"cert", "server"}, float64(ServerCert.Info().NotAfter.Unix())})
+	}
+	if RATLSCert != nil {
+		samples = append(samples, gauge{[]string{"cert", "ratls"}, float64(RATLSCert.Info().NotAfter.Unix())})
+	}
+	writeGauges(w, "tls_cert_expiry_timestamp_seconds", "Expiry of the TLS certificate in use, as a Unix timestamp.", samples...)
+}
+
+// MakeMetricsHandler implements /metrics: request, JWT fetch, resource,
+// attestation, container and TLS metrics in the Prometheus text format.
+func MakeMetricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+		var b strings.Builder
+		httpRequests.write(&b)
+		httpRequestDuration.write(&b)
+		jwtFetches.write(&b)
+		jwtFetchDuration.write(&b)
+		writeResourceGauges(&b)
+		writeAttestationGauges(&b)
+		writeContainerGauges(r.Context(), &b)
+		writeTLSGauges(&b)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = io.WriteString(w, b.String())
+	}
+}
@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	startFakeDocker(t, fakeDockerMux(t))
+	prevReportDir, prevRATLS, prevMode := ReportDir, RATLSCert, PrivateMode
+	defer func() { ReportDir, RATLSCert, PrivateMode = prevReportDir, prevRATLS, prevMode }()
+	ReportDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(ReportDir, CPUAttestationFile), []byte("quote"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	RATLSCert = &RATLSIssuer{info: CertInfo{NotAfter: time.Unix(1900000000, 0)}}
+	PrivateMode = false
+	prevResources, prevAudit := Resources, Audit
+	defer func() { Resources, Audit = prevResources, prevAudit }()
+	Audit = NewAuditLog("", 100, 0, 0)
+	Resources = NewResourceSampler(time.Second, time.Minute)
+	Resources.add(ResourceStats{SampledAt: time.Now(), MemoryUsedGB: 1.15, memoryUsed: 1234567891, Load5: 0.25,
+		Disks: []DiskStats{{Path: "/mnt/secure", UsedGB: 0.001, used: 987654, InodesUsed: 12, ReadOnly: true}}})
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	reg := NewRegistry(
+		Route{Path: "/metrics-test", Handler: ok, Group: GroupPublic},
+		Route{Path: "/metrics", Handler: MakeMetricsHandler(), Group: GroupMetrics},
+	)
+	mux := http.NewServeMux()
+	if err := reg.Mount(mux); err != nil {
+		t.Fatal(err)
+	}
+	handler := LoggingMiddleware(mux)
+	for _, path := range []string{"/metrics-test", "/.well-known/metrics-test", "/metrics-test-unknown/123"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for _, method := range []string{"FOO1", "FOO2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/metrics-test", nil))
+	}
+	failed := errors.New("unreachable")
+	observeJWTFetch("poc", time.Now(), &failed)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`secretvm_http_requests_total{method="GET",route="/metrics-test",code="200"} 2`,
+		`secretvm_http_requests_total{method="GET",route="other",code="404"} 1`,
+		`secretvm_http_request_duration_seconds_bucket{method="GET",route="/metrics-test",le="+Inf"} 2`,
+		`secretvm_http_request_duration_seconds_count{method="GET",route="/metrics-test"} 2`,
+		`secretvm_jwt_fetch_total{kind="poc",result="failure"} 1`,
+		`secretvm_jwt_fetch_duration_seconds_count{kind="poc"} 1`,
+		`secretvm_attestation_file_age_seconds{type="cpu"} `,
+		`secretvm_docker_up 1`,
+		`secretvm_container_state{container="console",state="exited"} 1`,
+		`secretvm_container_state{container="web",state="running"} 1`,
+		`secretvm_tls_cert_expiry_timestamp_seconds{cert="ratls"} 1.9e+09`,
+		"secretvm_memory_used_bytes 1.234567891e+09", // exact bytes, not the rounded GB
+		`secretvm_disk_used_bytes{path="/mnt/secure"} 987654`,
+		`secretvm_load_average{period="5m"} 0.25`,
+		`secretvm_disk_inodes_used{path="/mnt/secure"} 12`,
+		`secretvm_disk_read_only{path="/mnt/secure"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+	if !strings.Contains(body, `secretvm_http_requests_total{method="other",route="/metrics-test",code=`) || strings.Contains(body, "FOO") {
+		t.Errorf("unknown methods not folded into \"other\":\n%s", body)
+	}
+	if strings.Contains(body, `type="gpu"`) {
+		t.Error("age reported for a missing attestation file")
+	}
+}
+
+func TestFormatLabelsEscapes(t *testing.T) {
+	got := formatLabels([]string{"a", "b"}, labelKey([]string{`x"y`, "1\\2"}), "le", "0.5")
+	if want := `{a="x\"y",b="1\\2",le="0.5"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
@@ -18,8 +19,15 @@ func (rw *ResponseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController,
+// so streaming handlers can flush and adjust write deadlines.
+func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // LoggingMiddleware logs the remote address, requested URL, HTTP method, and response status code
-// for each incoming HTTP request, along with the time taken to process the request.
+// for each incoming HTTP request, along with the time taken to process the request, and records
+// the request in the /metrics counters and latency histograms.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Start timer
@@ -28,11 +36,16 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		// Create a wrapper for the response writer to capture the status code
 		wrapped := &ResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
+		// The matched route fills in its path for the request metrics
+		var route string
+		r = r.WithContext(context.WithValue(r.Context(), routeLabelKey{}, &route))
+
 		// Process the request
 		next.ServeHTTP(wrapped, r)
 
 		// Calculate request processing time
 		duration := time.Since(start)
+		observeRequest(r, route, wrapped.statusCode, duration)
 
 		// Log the request details including status code and duration
 		log.Printf("%s | %s %s | %d | %s | %v",
@@ -71,8 +84,9 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Add CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Log-Cursor, X-Redactions-Count, X-Log-Signature, X-Log-Signature-Timestamp, X-Log-Signature-Params, X-Log-Signature-Path, X-Log-Signature-Key, X-Compose-SHA256")
 
 		// Handle preflight requests
 		if r.Method == http.MethodOptions {
@@ -0,0 +1,192 @@
+// Package ratls issues and verifies remote-attested TLS certificates.
+//
+// The server generates its TLS keypair in memory, requests a TDX quote whose
+// report data commits to the SHA-256 of the certificate's SubjectPublicKeyInfo,
+// and embeds the quote in a self-issued X.509 certificate. A client that
+// checks the binding knows the TLS session terminates inside the attested VM.
+//
+// This package only checks the key binding. Verifying the quote itself
+// (signature chain, TCB status, measurements) is left to a QuoteVerifier,
+// e.g. one backed by Intel DCAP or Intel Trust Authority.
+package ratls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// OIDTDXQuote identifies the certificate extension carrying the raw TDX quote.
+var OIDTDXQuote = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 5, 5, 1, 6}
+
+// QuoteFunc obtains an attestation quote binding the given report data.
+type QuoteFunc func(reportData [64]byte) ([]byte, error)
+
+// QuoteVerifier validates a quote extracted from a peer certificate.
+type QuoteVerifier func(quote []byte) error
+
+// ReportData returns the report data committing to the DER-encoded
+// SubjectPublicKeyInfo: its SHA-256 followed by 32 zero bytes.
+func ReportData(spki []byte) [64]byte {
+	var rd [64]byte
+	sum := sha256.Sum256(spki)
+	copy(rd[:], sum[:])
+	return rd
+}
+
+// NewCertificate generates an in-memory ECDSA P-256 keypair, obtains a quote
+// over its public key and returns a self-issued certificate embedding it.
+// hosts are added as DNS or IP subject alternative names.
+func NewCertificate(getQuote QuoteFunc, hosts []string, validity time.Duration) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate key: %w", err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("marshal public key: %w", err)
+	}
+	quote, err := getQuote(ReportData(spki))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("obtain quote: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate serial: %w", err)
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:    serial,
+		Subject:         pkix.Name{CommonName: "SecretVM RA-TLS", Organization: []string{"SecretVM"}},
+		NotBefore:       now.Add(-time.Minute),
+		NotAfter:        now.Add(validity),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtraExtensions: []pkix.Extension{{Id: OIDTDXQuote, Value: quote}},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("create certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("parse certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// QuoteFromCertificate returns the quote embedded in cert, if any.
+func QuoteFromCertificate(cert *x509.Certificate) ([]byte, error) {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(OIDTDXQuote) {
+			return ext.Value, nil
+		}
+	}
+	return nil, errors.New("ratls: certificate carries no quote extension")
+}
+
+// TDX quote layout: a 48-byte header followed by the TD report body, whose
+// report data field starts 520 bytes in. Version 5 quotes prefix the body
+// with a 2-byte type and 4-byte size.
+const (
+	tdxHeaderLen        = 48
+	tdxTEETypeOffset    = 4
+	tdxTEEType          = 0x81
+	tdxReportDataOffset = 520
+	tdxV5BodyPrefixLen  = 6
+)
+
+// ReportDataFromQuote extracts the 64-byte report data from a TDX quote (version 4 or 5).
+func ReportDataFromQuote(quote []byte) ([64]byte, error) {
+	var rd [64]byte
+	if len(quote) < tdxHeaderLen {
+		return rd, errors.New("ratls: quote too short")
+	}
+	if binary.LittleEndian.Uint32(quote[tdxTEETypeOffset:]) != tdxTEEType {
+		return rd, errors.New("ratls: not a TDX quote")
+	}
+
+	off := tdxHeaderLen + tdxReportDataOffset
+	switch v := binary.LittleEndian.Uint16(quote); v {
+	case 4:
+	case 5:
+		off += tdxV5BodyPrefixLen
+	default:
+		return rd, fmt.Errorf("ratls: unsupported quote version %d", v)
+	}
+	if len(quote) < off+len(rd) {
+		return rd, errors.New("ratls: quote truncated before report data")
+	}
+	copy(rd[:], quote[off:off+len(rd)])
+	return rd, nil
+}
+
+// VerifyCertificate checks that cert is self-signed and that its embedded quote
+// binds cert's public key, then passes the quote to verify if non-nil.
+// It returns the quote so callers can inspect measurements.
+func VerifyCertificate(cert *x509.Certificate, verify QuoteVerifier) ([]byte, error) {
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return nil, fmt.Errorf("ratls: certificate signature: %w", err)
+	}
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, errors.New("ratls: certificate is not valid at the current time")
+	}
+	quote, err := QuoteFromCertificate(cert)
+	if err != nil {
+		return nil, err
+	}
+	got, err := ReportDataFromQuote(quote)
+	if err != nil {
+		return nil, err
+	}
+	want := ReportData(cert.RawSubjectPublicKeyInfo)
+	if !bytes.Equal(got[:], want[:]) {
+		return nil, errors.New("ratls: quote report data does not match the certificate key")
+	}
+	if verify != nil {
+		if err := verify(quote); err != nil {
+			return nil, fmt.Errorf("ratls: quote rejected: %w", err)
+		}
+	}
+	return quote, nil
+}
+
+// ClientConfig returns a TLS client configuration that accepts a server
+// only if it presents a valid RA-TLS certificate. Standard chain
+// verification is replaced by the quote binding check, so verify should
+// validate the quote itself in production.
+func ClientConfig(serverName string, verify QuoteVerifier) *tls.Config {
+	return &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true, // chain trust comes from the quote, checked below
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("ratls: no peer certificate")
+			}
+			_, err := VerifyCertificate(cs.PeerCertificates[0], verify)
+			return err
+		},
+	}
+}
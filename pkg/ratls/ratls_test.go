@@ -0,0 +1,78 @@
+package ratls
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeTDXQuote builds a minimal version-4 TDX quote carrying reportData.
+func fakeTDXQuote(reportData [64]byte) ([]byte, error) {
+	q := make([]byte, tdxHeaderLen+tdxReportDataOffset+64+16)
+	binary.LittleEndian.PutUint16(q, 4)
+	binary.LittleEndian.PutUint32(q[tdxTEETypeOffset:], tdxTEEType)
+	copy(q[tdxHeaderLen+tdxReportDataOffset:], reportData[:])
+	return q, nil
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	cert, err := NewCertificate(fakeTDXQuote, []string{"localhost", "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quote, err := VerifyCertificate(cert.Leaf, nil)
+	if err != nil {
+		t.Fatalf("valid certificate rejected: %v", err)
+	}
+	if len(quote) == 0 {
+		t.Fatal("no quote returned")
+	}
+
+	rejecting := func([]byte) error { return errors.New("untrusted TCB") }
+	if _, err := VerifyCertificate(cert.Leaf, rejecting); err == nil {
+		t.Error("quote verifier error not propagated")
+	}
+
+	// A quote bound to a different key must be rejected.
+	other, err := NewCertificate(func([64]byte) ([]byte, error) {
+		var rd [64]byte
+		rd[0] = 1
+		return fakeTDXQuote(rd)
+	}, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyCertificate(other.Leaf, nil); err == nil {
+		t.Error("mismatched report data accepted")
+	}
+}
+
+func TestClientConfigHandshake(t *testing.T) {
+	cert, err := NewCertificate(fakeTDXQuote, []string{"127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: ClientConfig("", nil)}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("RA-TLS handshake failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// The default httptest certificate carries no quote.
+	plain := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	if _, err := client.Get(plain.URL); err == nil {
+		t.Error("server without quote accepted")
+	}
+}
@@ -0,0 +1,34 @@
+package ratls
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// TSMQuote returns a QuoteFunc backed by the Linux configfs-tsm interface
+// rooted at dir (normally /sys/kernel/config/tsm/report). Each call creates
+// a fresh report entry, writes the report data and reads back the quote.
+func TSMQuote(dir string) QuoteFunc {
+	return func(reportData [64]byte) ([]byte, error) {
+		entry := filepath.Join(dir, "ratls-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+		if err := os.Mkdir(entry, 0700); err != nil {
+			return nil, fmt.Errorf("create tsm report entry: %w", err)
+		}
+		defer os.Remove(entry)
+
+		if err := os.WriteFile(filepath.Join(entry, "inblob"), reportData[:], 0600); err != nil {
+			return nil, fmt.Errorf("write tsm inblob: %w", err)
+		}
+		quote, err := os.ReadFile(filepath.Join(entry, "outblob"))
+		if err != nil {
+			return nil, fmt.Errorf("read tsm outblob: %w", err)
+		}
+		if len(quote) == 0 {
+			return nil, fmt.Errorf("empty quote from %s", entry)
+		}
+		return quote, nil
+	}
+}
@@ -0,0 +1,176 @@
+// pkg/redact.go
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// redactedMark replaces every redacted secret.
+const redactedMark = "[REDACTED]"
+
+// minSecretLen is the shortest configured secret that is redacted literally;
+// shorter values would match ordinary text everywhere.
+const minSecretLen = 4
+
+// builtinRedactPatterns match common credentials. When a pattern has a group
+// named "secret", only that group is replaced and the rest stays readable.
+var builtinRedactPatterns = []string{
+	// Authorization: Bearer <token>
+	`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`,
+	// x-api-key: <key>, "x-api-key": "<key>", x-api-key=<key>
+	`(?i)\bx-api-key["']?\s*[:=]\s*["']?(?P<secret>[^\s"',;]+)`,
+	// JSON Web Tokens
+	`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`,
+	// PEM private keys on a single line
+	`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`,
+}
+
+var (
+	pemKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----`)
+	pemKeyEnd   = regexp.MustCompile(`-----END [A-Z0-9 ]*PRIVATE KEY-----`)
+)
+
+// Redactor removes secrets from log messages before they leave the server.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor combines the built-in patterns, the literal secrets and the
+// extra user patterns. Invalid extra patterns are returned as an error
+// together with a redactor that uses the valid ones.
+func NewRedactor(secrets []string, extra []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range builtinRedactPatterns {
+		r.patterns = append(r.patterns, regexp.MustCompile(p))
+	}
+	for _, s := range secrets {
+		if len(s) >= minSecretLen {
+			r.patterns = append(r.patterns, regexp.MustCompile(regexp.QuoteMeta(s)))
+		}
+	}
+	var bad []string
+	for _, p := range extra {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("%q: %v", p, err))
+			continue
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	if len(bad) > 0 {
+		return r, fmt.Errorf("invalid redaction patterns: %s", strings.Join(bad, "; "))
+	}
+	return r, nil
+}
+
+// Redact returns s with every secret replaced and the number of replacements.
+func (r *Redactor) Redact(s string) (string, int) {
+	total := 0
+	for _, re := range r.patterns {
+		var n int
+		s, n = redactPattern(re, s)
+		total += n
+	}
+	return s, total
+}
+
+// redactPattern replaces the matches of re in s, or only their "secret"
+// group when re has one.
+func redactPattern(re *regexp.Regexp, s string) (string, int) {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, 0
+	}
+	group := re.SubexpIndex("secret")
+	var b strings.Builder
+	last, n := 0, 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if group > 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		if start < 0 || start == end {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(redactedMark)
+		last = end
+		n++
+	}
+	b.WriteString(s[last:])
+	return b.String(), n
+}
+
+// RedactSession redacts a sequence of log entries. Besides the per-message
+// patterns it blanks out PEM private keys split over several lines, which
+// it tracks per source and stream.
+type RedactSession struct {
+	r     *Redactor
+	inKey map[string]bool
+	Count int // redactions so far
+}
+
+// NewSession starts redacting a new sequence of entries.
+func (r *Redactor) NewSession() *RedactSession {
+	return &RedactSession{r: r, inKey: make(map[string]bool)}
+}
+
+// Entry redacts e.Message in place.
+func (rs *RedactSession) Entry(e *LogEntry) {
+	key := e.Source + "\x00" + e.Stream
+	msg := e.Message
+	if rs.inKey[key] {
+		if loc := pemKeyEnd.FindStringIndex(msg); loc != nil {
+			rs.inKey[key] = false
+			msg = redactedMark + msg[loc[1]:]
+		} else {
+			msg = redactedMark
+		}
+		rs.Count++
+	}
+
+	msg, n := rs.r.Redact(msg)
+	rs.Count += n
+
+	// A key block starting here and continuing on the following lines.
+	if loc := pemKeyBegin.FindStringIndex(msg); loc != nil && !pemKeyEnd.MatchString(msg[loc[1]:]) {
+		rs.inKey[key] = true
+		msg = msg[:loc[0]] + redactedMark
+		rs.Count++
+	}
+	e.Message = msg
+}
+
+// Entries redacts every entry in place and returns the number of redactions.
+func (rs *RedactSession) Entries(entries []LogEntry) int {
+	before := rs.Count
+	for i := range entries {
+		rs.Entry(&entries[i])
+	}
+	return rs.Count - before
+}
+
+// newLogRedactor builds the redactor for the configured secrets: the dev
+// token, the ITA API keys and the JSON array of regular expressions in
+// SECRETVM_LOG_REDACT_PATTERNS.
+func newLogRedactor(patternsJSON string) *Redactor {
+	secrets := []string{AccessToken}
+	for _, k := range ItaKeys {
+		secrets = append(secrets, k.ApiKey)
+	}
+	var extra []string
+	if patternsJSON != "" {
+		if err := json.Unmarshal([]byte(patternsJSON), &extra); err != nil {
+			log.Printf("Warning: Failed to parse SECRETVM_LOG_REDACT_PATTERNS: %v", err)
+		}
+	}
+	r, err := NewRedactor(secrets, extra)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return r
+}
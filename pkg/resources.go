@@ -0,0 +1,217 @@
+// pkg/resources.go
+package pkg
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// ResourceSampler measures the VM's resource usage at a fixed interval in
+// the background and keeps the samples of the retention period in a ring
+// buffer, so /resources never blocks on CPU sampling.
+type ResourceSampler struct {
+	interval time.Duration
+
+	mu              sync.Mutex
+	samples         []ResourceStats // ring buffer
+	next            int             // index of the slot written next
+	full            bool
+	containers      []ContainerResources // of the latest sample
+	containersError string
+	lastNet         *net.IOCountersStat
+	dockerRoot      string // detected Docker data root, once known
+}
+
+// NewResourceSampler creates a sampler keeping retention worth of samples
+// taken every interval.
+func NewResourceSampler(interval, retention time.Duration) *ResourceSampler {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	size := int(retention / interval)
+	if size < 1 {
+		size = 1
+	}
+	return &ResourceSampler{interval: interval, samples: make([]ResourceStats, size)}
+}
+
+// Interval returns the time between samples.
+func (s *ResourceSampler) Interval() time.Duration {
+	return s.interval
+}
+
+// Run samples until ctx is cancelled. CPU usage, of the VM and of the
+// containers, is measured over each interval.
+func (s *ResourceSampler) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		s.collect(ctx)
+	}
+}
+
+// collect takes one sample, which takes one interval.
+func (s *ResourceSampler) collect(ctx context.Context) {
+	containers, containersErr := startContainerSampler(ctx)
+
+	// CPU usage over the interval; this is what paces the loop.
+	start := time.Now()
+	cpus, err := cpu.PercentWithContext(ctx, s.interval, false)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil || len(cpus) == 0 {
+		// Still wait for the interval rather than spinning.
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(start.Add(s.interval))):
+		}
+		cpus = []float64{0}
+	}
+
+	stats := ResourceStats{SampledAt: time.Now().UTC(), CPUPercent: round2(cpus[0])}
+	var vmTotal uint64
+	if vm, err := mem.VirtualMemory(); err == nil {
+		vmTotal = vm.Total
+		stats.memoryUsed, stats.memoryTotal = vm.Used, vm.Total
+		stats.MemoryUsedGB, stats.MemoryTotalGB, stats.MemoryPercent = toGB(vm.Used), toGB(vm.Total), round2(vm.UsedPercent)
+	}
+	if sw, err := mem.SwapMemory(); err == nil {
+		stats.swapUsed, stats.swapTotal = sw.Used, sw.Total
+		stats.SwapUsedGB, stats.SwapTotalGB, stats.SwapPercent = toGB(sw.Used), toGB(sw.Total), round2(sw.UsedPercent)
+	}
+	if du, err := disk.Usage(FsMountPath); err == nil {
+		stats.diskUsed, stats.diskTotal = du.Used, du.Total
+		stats.DiskUsedGB, stats.DiskTotalGB, stats.DiskPercent = toGB(du.Used), toGB(du.Total), round2(du.UsedPercent)
+	}
+	if s.dockerRoot == "" {
+		// Only this goroutine writes dockerRoot; retried until Docker answers.
+		s.dockerRoot, _ = dockerRootDir(ctx)
+	}
+	refreshComposeCheck(ctx) // for /status
+	stats.Disks = sampleDisks(DiskMounts, s.dockerRoot)
+	stats.Warnings = diskWarnings(stats.Disks, float64(DiskWarnPercent), float64(DiskCriticalPercent))
+	if avg, err := load.Avg(); err == nil {
+		stats.Load1, stats.Load5, stats.Load15 = avg.Load1, avg.Load5, avg.Load15
+	}
+	counters, netErr := net.IOCounters(false)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if netErr == nil && len(counters) > 0 {
+		c := counters[0]
+		stats.NetRxBytes, stats.NetTxBytes = c.BytesRecv, c.BytesSent
+		if prev := s.lastNet; prev != nil && c.BytesRecv >= prev.BytesRecv && c.BytesSent >= prev.BytesSent {
+			secs := s.interval.Seconds()
+			stats.NetRxBytesPerSec = round2(float64(c.BytesRecv-prev.BytesRecv) / secs)
+			stats.NetTxBytesPerSec = round2(float64(c.BytesSent-prev.BytesSent) / secs)
+		}
+		s.lastNet = &c
+	}
+	if containersErr != nil {
+		s.containers, s.containersError = nil, containersErr.Error()
+	} else {
+		s.containers, s.containersError = containers.finish(vmTotal), ""
+	}
+	s.addLocked(stats)
+}
+
+// add appends a sample, overwriting the oldest once the buffer is full.
+func (s *ResourceSampler) add(stats ResourceStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addLocked(stats)
+}
+
+func (s *ResourceSampler) addLocked(stats ResourceStats) {
+	s.samples[s.next] = stats
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// Latest returns the newest sample, with the container usage measured in the
+// same interval; ok is false before the first sample is taken.
+func (s *ResourceSampler) Latest() (stats ResourceStats, containers []ContainerResources, containersErr string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.full && s.next == 0 {
+		return ResourceStats{}, nil, "", false
+	}
+	i := (s.next - 1 + len(s.samples)) % len(s.samples)
+	return s.samples[i], s.containers, s.containersError, true
+}
+
+// History returns the samples of the last window, oldest first.
+func (s *ResourceSampler) History(window time.Duration) []ResourceStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := time.Now().Add(-window)
+	out := []ResourceStats{}
+	n, start := s.next, 0
+	if s.full {
+		n, start = len(s.samples), s.next
+	}
+	for k := 0; k < n; k++ {
+		stats := s.samples[(start+k)%len(s.samples)]
+		if !stats.SampledAt.Before(cutoff) {
+			out = append(out, stats)
+		}
+	}
+	return out
+}
+
+// Retention returns how far back the ring buffer reaches when full.
+func (s *ResourceSampler) Retention() time.Duration {
+	return time.Duration(len(s.samples)) * s.interval
+}
+
+// toGB converts bytes to GB rounded to 3 decimal places.
+func toGB(b uint64) float64 {
+	gb := float64(b) / (1024 * 1024 * 1024)
+	return math.Round(gb*1000) / 1000
+}
+
+// ResourceHistory is the /resources/history response.
+type ResourceHistory struct {
+	IntervalSeconds float64         `json:"interval_seconds"`
+	Samples         []ResourceStats `json:"samples"`
+}
+
+// MakeResourcesHistoryHandler implements /resources/history: the samples of
+// the background sampler, oldest first.
+//
+// - ?window=<duration> how far back to go, e.g. 15m or 6h (default 1h, at most the retention)
+func MakeResourcesHistoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed", "Only GET requests are supported")
+			return
+		}
+		window := time.Hour
+		if v := r.URL.Query().Get("window"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				respondWithError(w, http.StatusBadRequest, "Invalid window", "window must be a positive duration such as 15m or 6h")
+				return
+			}
+			window = d
+		}
+		if max := Resources.Retention(); window > max {
+			window = max
+		}
+		respondWithJSON(w, http.StatusOK, ResourceHistory{
+			IntervalSeconds: Resources.Interval().Seconds(),
+			Samples:         Resources.History(window),
+		})
+	}
+}
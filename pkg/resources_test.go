@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResourceSamplerRing(t *testing.T) {
+	s := NewResourceSampler(time.Minute, 3*time.Minute)
+	if _, _, _, ok := s.Latest(); ok {
+		t.Fatal("latest sample before sampling")
+	}
+	now := time.Now()
+	for i := 5; i >= 1; i-- {
+		s.add(ResourceStats{SampledAt: now.Add(-time.Duration(i) * time.Minute), CPUPercent: float64(i)})
+	}
+	// Only the three newest samples are kept, oldest first.
+	got := s.History(time.Hour)
+	if len(got) != 3 || got[0].CPUPercent != 3 || got[2].CPUPercent != 1 {
+		t.Fatalf("history = %+v", got)
+	}
+	if got := s.History(150 * time.Second); len(got) != 2 {
+		t.Errorf("window 2m30s: %d samples", len(got))
+	}
+	if latest, _, _, _ := s.Latest(); latest.CPUPercent != 1 {
+		t.Errorf("latest = %+v", latest)
+	}
+}
+
+func TestResourcesHandlers(t *testing.T) {
+	prev := Resources
+	defer func() { Resources = prev }()
+	Resources = NewResourceSampler(50*time.Millisecond, time.Hour)
+
+	rec := httptest.NewRecorder()
+	MakeResourcesHandler()(rec, httptest.NewRequest(http.MethodGet, "/resources", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("before the first sample: %d", rec.Code)
+	}
+
+	// One real sample from the host.
+	Resources.collect(context.Background())
+	start := time.Now()
+	rec = httptest.NewRecorder()
+	MakeResourcesHandler()(rec, httptest.NewRequest(http.MethodGet, "/resources?containers=true", nil))
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("/resources took %s", d)
+	}
+	var stats ResourceStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil || stats.MemoryTotalGB <= 0 || stats.SampledAt.IsZero() {
+		t.Fatalf("/resources = %s", rec.Body)
+	}
+	if stats.Containers == nil && stats.ContainersError == "" {
+		t.Errorf("containers=true without containers or containers_error: %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	MakeResourcesHistoryHandler()(rec, httptest.NewRequest(http.MethodGet, "/resources/history?window=10m", nil))
+	var hist ResourceHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &hist); err != nil || len(hist.Samples) != 1 || hist.IntervalSeconds != 0.05 {
+		t.Errorf("/resources/history = %s", rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	MakeResourcesHistoryHandler()(rec, httptest.NewRequest(http.MethodGet, "/resources/history?window=-1h", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("negative window: status %d", rec.Code)
+	}
+}
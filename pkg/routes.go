@@ -0,0 +1,318 @@
+// pkg/routes.go
+package pkg
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// AccessGroup names the access-control group an endpoint belongs to.
+// Private groups are opened or closed together by one bit of EndpointsMask,
+// so the plain and HTML variants of an endpoint always share a decision.
+type AccessGroup string
+
+const (
+	GroupPublic        AccessGroup = "public" // always served
+	GroupLogs          AccessGroup = "logs"
+	GroupDockerCompose AccessGroup = "docker-compose"
+	GroupServices      AccessGroup = "services"
+	GroupVMUpgrades    AccessGroup = "vm-upgrades"
+	GroupResources     AccessGroup = "resources"
+	GroupMetrics       AccessGroup = "metrics"
+	GroupOwner         AccessGroup = "owner" // always requires the access token
+)
+
+// groupBits maps each private group to its EndpointsMask position (leftmost char -> bit 0).
+var groupBits = map[AccessGroup]int{
+	GroupLogs:          0,
+	GroupDockerCompose: 1,
+	GroupServices:      2,
+	GroupVMUpgrades:    3,
+	GroupResources:     4,
+	GroupMetrics:       5,
+}
+
+// WellKnownPrefix is the path prefix under which every route is mirrored.
+const WellKnownPrefix = "/.well-known"
+
+// Route declares an endpoint once; the mux entries, the /.well-known mirror,
+// the access guard and the endpoint index are all derived from it.
+type Route struct {
+	Path        string           // canonical path, e.g. "/cpu"
+	Summary     string           // one-line description for the endpoint index
+	Handler     http.HandlerFunc // plain (machine-readable) variant
+	HTML        http.HandlerFunc // optional rendered variant, served at Path + ".html"
+	Group       AccessGroup      // access-control group
+	Aliases     []string         // additional paths served by Handler
+	Hidden      bool             // omit from the endpoint index (static assets)
+	Methods     []string         // accepted methods (default GET)
+	ContentType string           // media type of Handler responses (default application/json)
+	Enabled     func() bool      // reports whether the endpoint is enabled on this VM (default always)
+}
+
+// methods returns the accepted methods, defaulting to GET.
+func (rt Route) methods() []string {
+	if len(rt.Methods) == 0 {
+		return []string{http.MethodGet}
+	}
+	return rt.Methods
+}
+
+// contentType returns the response media type, defaulting to JSON.
+func (rt Route) contentType() string {
+	if rt.ContentType == "" {
+		return "application/json"
+	}
+	return rt.ContentType
+}
+
+// enabled reports whether the endpoint is enabled.
+func (rt Route) enabled() bool {
+	return rt.Enabled == nil || rt.Enabled()
+}
+
+// Paths returns every path the route answers on, excluding the mirror.
+func (rt Route) Paths() []string {
+	paths := []string{rt.Path}
+	if rt.HTML != nil {
+		paths = append(paths, rt.Path+".html")
+	}
+	return append(paths, rt.Aliases...)
+}
+
+// Registry holds the declared routes of the server.
+type Registry struct {
+	routes []Route
+}
+
+// NewRegistry creates a registry from the given routes.
+func NewRegistry(routes ...Route) *Registry {
+	return &Registry{routes: routes}
+}
+
+// Add declares more routes.
+func (reg *Registry) Add(routes ...Route) {
+	reg.routes = append(reg.routes, routes...)
+}
+
+// Routes returns the declared routes in declaration order.
+func (reg *Registry) Routes() []Route {
+	return reg.routes
+}
+
+// Mount registers every route, its HTML variant and aliases on mux, each
+// wrapped in the guard of its group, and mirrors them under WellKnownPrefix.
+// The guard is bound at registration, so access decisions never depend on
+// how the request path was rewritten.
+func (reg *Registry) Mount(mux *http.ServeMux) error {
+	seen := make(map[string]bool)
+	for _, rt := range reg.routes {
+		if err := validGroup(rt.Group); err != nil {
+			return fmt.Errorf("route %s: %w", rt.Path, err)
+		}
+		if rt.Handler == nil {
+			return fmt.Errorf("route %s: no handler", rt.Path)
+		}
+		for _, p := range rt.Paths() {
+			if seen[p] {
+				return fmt.Errorf("route %s: path %s declared twice", rt.Path, p)
+			}
+			seen[p] = true
+
+			h := rt.Handler
+			if rt.HTML != nil && p == rt.Path+".html" {
+				h = rt.HTML
+			}
+			guarded := Guard(rt.Group, h)
+			route := p // the mirror shares the metrics of the canonical path
+			labeled := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				withRouteLabel(r, route)
+				guarded(w, r)
+			})
+			pattern := p
+			if p == "/" {
+				// Match the root exactly rather than every unregistered path.
+				pattern = "/{$}"
+			}
+			mux.Handle(pattern, labeled)
+			mux.Handle(WellKnownPrefix+pattern, http.StripPrefix(WellKnownPrefix, labeled))
+		}
+	}
+	return nil
+}
+
+// EndpointInfo describes a registered endpoint in the generated index.
+type EndpointInfo struct {
+	Path         string      `json:"path"`
+	HTML         string      `json:"html,omitempty"`
+	Aliases      []string    `json:"aliases,omitempty"`
+	WellKnown    string      `json:"well_known"`
+	Methods      []string    `json:"methods"`
+	ContentTypes []string    `json:"content_types"`
+	Group        AccessGroup `json:"group"`
+	Access       string      `json:"access"`
+	Enabled      bool        `json:"enabled"`
+	Summary      string      `json:"summary,omitempty"`
+}
+
+// Access levels reported in the endpoint index.
+const (
+	AccessLevelPublic = "public" // served to anyone
+	AccessLevelOpen   = "open"   // private group opened by the endpoints mask
+	AccessLevelToken  = "token"  // requires the access token
+)
+
+// accessLevel reports how group is currently protected, given private mode
+// and the endpoints mask.
+func accessLevel(group AccessGroup) string {
+	switch group {
+	case GroupPublic:
+		return AccessLevelPublic
+	case GroupOwner:
+		return AccessLevelToken
+	}
+	if !PrivateMode {
+		return AccessLevelPublic
+	}
+	if idx, ok := groupBits[group]; ok && bitIsOpen(EndpointsMask, idx) {
+		return AccessLevelOpen
+	}
+	return AccessLevelToken
+}
+
+// Index returns the visible endpoints sorted by path, with their access
+// level evaluated against the current configuration.
+func (reg *Registry) Index() []EndpointInfo {
+	var out []EndpointInfo
+	for _, rt := range reg.routes {
+		if rt.Hidden {
+			continue
+		}
+		info := EndpointInfo{
+			Path:         rt.Path,
+			Aliases:      rt.Aliases,
+			WellKnown:    WellKnownPrefix + rt.Path,
+			Methods:      rt.methods(),
+			ContentTypes: []string{rt.contentType()},
+			Group:        rt.Group,
+			Access:       accessLevel(rt.Group),
+			Enabled:      rt.enabled(),
+			Summary:      rt.Summary,
+		}
+		if rt.HTML != nil {
+			info.HTML = rt.Path + ".html"
+			info.ContentTypes = append(info.ContentTypes, "text/html")
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+func validGroup(g AccessGroup) error {
+	if g == GroupPublic || g == GroupOwner {
+		return nil
+	}
+	if _, ok := groupBits[g]; ok {
+		return nil
+	}
+	return fmt.Errorf("unknown access group %q", g)
+}
+
+// Guard wraps h with the access check of group.
+func Guard(group AccessGroup, h http.HandlerFunc) http.HandlerFunc {
+	switch group {
+	case GroupPublic:
+		return h
+	case GroupOwner:
+		return OwnerGuard(h)
+	default:
+		return PrivateGuard(group, h)
+	}
+}
+
+// DefaultRoutes declares every API endpoint served by the attestation server.
+func DefaultRoutes(secure bool) []Route {
+	const textPlain = "text/plain"
+	itaEnabled := func() bool { return EnableItaJwt }
+	pocEnabled := func() bool { return EnablePocJwt }
+
+	return []Route{
+		{Path: "/status", Summary: "Server and orchestrator status", Handler: StatusHandler, Group: GroupPublic},
+		{Path: "/healthz", Summary: "Liveness probe", Handler: HealthzHandler, Group: GroupPublic},
+		{Path: "/readyz", Summary: "Readiness probe", Handler: ReadyzHandler, Group: GroupPublic},
+
+		// Attestation reports
+		{Path: "/gpu", Summary: "NVIDIA confidential GPU attestation report",
+			Handler: MakeAttestationFileHandler(GPUAttestationFile, "GPU"),
+			HTML:    MakeAttestationHTMLHandler(GPUAttestationFile, "GPU"), Group: GroupPublic, ContentType: textPlain},
+		{Path: "/cpu", Summary: "CPU attestation quote",
+			Handler: MakeAttestationFileHandler(CPUAttestationFile, "CPU"),
+			HTML:    MakeAttestationHTMLHandler(CPUAttestationFile, "CPU"), Group: GroupPublic, ContentType: textPlain},
+		{Path: "/self", Summary: "Self attestation report",
+			Handler: MakeAttestationFileHandler(SelfAttestationFile, "Self"),
+			HTML:    MakeAttestationHTMLHandler(SelfAttestationFile, "Self"), Group: GroupPublic, ContentType: textPlain},
+
+		// Dynamic JWTs
+		{Path: "/ita-jwt", Summary: "Intel Trust Authority JWTs",
+			Handler: MakeItaJwtHandler(), HTML: MakeItaJwtHTMLHandler(), Group: GroupPublic, Enabled: itaEnabled},
+		{Path: "/poc-jwt", Summary: "Proof of Cloud JWT",
+			Handler: MakePocJwtHandler(), HTML: MakePocJwtHTMLHandler(), Group: GroupPublic,
+			ContentType: textPlain, Enabled: pocEnabled},
+
+		// Signing keys
+		{Path: "/publickey_ed25519", Summary: "ed25519 public key for verifiable message signing",
+			Handler: MakePublicKeyHandler(PublicKeyEd25519Path, "ed25519"),
+			HTML:    MakePublicKeyHTMLHandler(PublicKeyEd25519Path, "ed25519"), Group: GroupPublic, ContentType: textPlain},
+		{Path: "/publickey_secp256k1", Summary: "secp256k1 public key for verifiable message signing",
+			Handler: MakePublicKeyHandler(PublicKeySecp256k1Path, "secp256k1"),
+			HTML:    MakePublicKeyHTMLHandler(PublicKeySecp256k1Path, "secp256k1"), Group: GroupPublic, ContentType: textPlain},
+
+		// Private workload data
+		{Path: "/logs", Summary: "VM service and container logs",
+			Handler: MakeVMLogsHandler(secure), HTML: MakeVMLiveLogsHandler(), Group: GroupLogs, ContentType: textPlain},
+		{Path: "/logs/stream", Summary: "Live VM logs as Server-Sent Events",
+			Handler: MakeVMLogsStreamHandler(secure), Group: GroupLogs, ContentType: "text/event-stream"},
+		{Path: "/logs/export", Summary: "Diagnostic archive of logs, container state and status",
+			Handler: MakeLogsExportHandler(secure), Group: GroupLogs, ContentType: "application/gzip"},
+		{Path: "/alerts", Summary: "Firing log alert rules",
+			Handler: MakeAlertsHandler(), Group: GroupLogs},
+		{Path: "/docker-compose", Summary: "Workload docker-compose file",
+			Handler: MakeDockerComposeFileHandler(), HTML: MakeDockerComposeHTMLHandler(), Group: GroupDockerCompose,
+			ContentType: "text/yaml"},
+		{Path: "/docker-compose.json", Summary: "Services, images, ports, volumes and environment names of the docker-compose file",
+			Handler: MakeDockerComposeJSONHandler(), Group: GroupDockerCompose},
+		{Path: "/docker-compose/verify", Summary: "Running images checked against the docker-compose pins",
+			Handler: MakeImagePinsHandler(), Group: GroupDockerCompose},
+		{Path: "/services", Summary: "Available log sources",
+			Handler: MakeServicesHandler(), Group: GroupServices},
+		{Path: "/services/events", Summary: "Container lifecycle and health event history",
+			Handler: MakeServiceEventsHandler(), Group: GroupServices},
+		{Path: "/vm_upgrades", Summary: "Upgrade filters registered for this VM",
+			Handler: MakeVMUpdatesHandler(), HTML: MakeVMUpdatesHTMLHandler(), Group: GroupVMUpgrades},
+		{Path: "/resources", Summary: "CPU, memory and disk usage",
+			Handler: MakeResourcesHandler(), HTML: MakeResourcesHTMLHandler(), Group: GroupResources},
+		{Path: "/resources/history", Summary: "Resource usage samples of a time window",
+			Handler: MakeResourcesHistoryHandler(), Group: GroupResources},
+		{Path: "/metrics", Summary: "Prometheus metrics",
+			Handler: MakeMetricsHandler(), Group: GroupMetrics, ContentType: "text/plain; version=0.0.4"},
+
+		// Owner-only
+		{Path: "/audit", Summary: "Audit trail of guarded endpoint access",
+			Handler: MakeAuditHandler(), Group: GroupOwner},
+		{Path: "/services/{name}/{action}", Summary: "Restart, stop or start a container of the compose project",
+			Handler: MakeContainerControlHandler(), Group: GroupOwner, Methods: []string{http.MethodPost},
+			Enabled: func() bool { return EnableContainerControl }},
+	}
+}
+
+// IndexRoutes declares the self-describing endpoints generated from reg.
+func (reg *Registry) IndexRoutes() []Route {
+	return []Route{
+		{Path: "/", Summary: "Index of the endpoints served by this VM",
+			Handler: MakeIndexHandler(reg), Group: GroupPublic},
+		{Path: "/openapi.json", Summary: "OpenAPI 3 description of the endpoints served by this VM",
+			Handler: MakeOpenAPIHandler(reg), Group: GroupPublic},
+	}
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegistryMountMirrorsGuard(t *testing.T) {
+	prevMode, prevToken, prevMask := PrivateMode, AccessToken, EndpointsMask
+	prevAudit, prevLockout := Audit, Lockout
+	defer func() {
+		PrivateMode, AccessToken, EndpointsMask = prevMode, prevToken, prevMask
+		Audit, Lockout = prevAudit, prevLockout
+	}()
+
+	PrivateMode = true
+	AccessToken = "secret"
+	EndpointsMask = "01000"
+	Audit = NewAuditLog(filepath.Join(t.TempDir(), "audit.log"), 100, 0, 0)
+	Lockout = NewLockoutTracker(5, time.Minute, time.Hour)
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	reg := NewRegistry(
+		Route{Path: "/open", Handler: ok, HTML: ok, Group: GroupDockerCompose},
+		Route{Path: "/closed", Handler: ok, Group: GroupLogs, Aliases: []string{"/closed-alias"}},
+		Route{Path: "/pub", Handler: ok, Group: GroupPublic},
+	)
+	mux := http.NewServeMux()
+	if err := reg.Mount(mux); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/open", http.StatusOK},
+		{"/open.html", http.StatusOK},
+		{"/.well-known/open.html", http.StatusOK},
+		{"/closed", http.StatusUnauthorized},
+		{"/closed-alias", http.StatusUnauthorized},
+		{"/.well-known/closed", http.StatusUnauthorized},
+		{"/.well-known/closed-alias", http.StatusUnauthorized},
+		{"/pub", http.StatusOK},
+		{"/.well-known/pub", http.StatusOK},
+	}
+	for _, tc := range tests {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+		if rr.Code != tc.want {
+			t.Errorf("%s: got %d want %d", tc.path, rr.Code, tc.want)
+		}
+	}
+
+	if got := len(reg.Index()); got != 3 {
+		t.Errorf("index entries: got %d want 3", got)
+	}
+}
+
+func TestRegistryRejectsUnknownGroup(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	reg := NewRegistry(Route{Path: "/x", Handler: ok, Group: "typo"})
+	if err := reg.Mount(http.NewServeMux()); err == nil {
+		t.Fatal("expected error for unknown access group")
+	}
+}
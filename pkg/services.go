@@ -0,0 +1,113 @@
+// pkg/services.go
+package pkg
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ServiceInfo describes a log source in /services?detail=true. Only Name and
+// Kind are set for the secretvm system services.
+type ServiceInfo struct {
+	Name         string            `json:"name"`
+	Kind         string            `json:"kind"` // system or container
+	ID           string            `json:"id,omitempty"`
+	Image        string            `json:"image,omitempty"`        // reference the container was created from
+	ImageID      string            `json:"image_id,omitempty"`     // local image ID (sha256 of the image config)
+	ImageDigest  string            `json:"image_digest,omitempty"` // registry manifest digest, "repo@sha256:..."
+	State        string            `json:"state,omitempty"`
+	Health       string            `json:"health,omitempty"` // healthy, unhealthy or starting; empty without a healthcheck
+	RestartCount int               `json:"restart_count"`
+	StartedAt    *time.Time        `json:"started_at,omitempty"`
+	FinishedAt   *time.Time        `json:"finished_at,omitempty"`
+	ExitCode     int               `json:"exit_code"`
+	Ports        []DockerPort      `json:"ports,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Units        []string          `json:"units,omitempty"` // systemd units of the system logs
+	Error        string            `json:"error,omitempty"` // set when the container could not be inspected
+}
+
+// ServicesDetail is the /services?detail=true response. Error is set when the
+// Docker Engine API is unreachable; Services then only holds secretvm.
+type ServicesDetail struct {
+	Services []ServiceInfo `json:"services"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// listServiceDetails inspects every container, running and stopped.
+func listServiceDetails(ctx context.Context) ServicesDetail {
+	out := ServicesDetail{Services: []ServiceInfo{{Name: "secretvm", Kind: "system", Units: LogUnits}}}
+	containers, err := Docker.ListContainers(ctx, true)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name() < containers[j].Name() })
+
+	digests := make(map[string]string) // image ID -> digest
+	for _, c := range containers {
+		info := ServiceInfo{
+			Name:    c.Name(),
+			Kind:    "container",
+			ID:      c.ID,
+			Image:   c.Image,
+			ImageID: c.ImageID,
+			State:   c.State,
+			Ports:   c.Ports,
+			Labels:  c.Labels,
+		}
+		if d, ok := digests[c.ImageID]; ok || c.ImageID == "" {
+			info.ImageDigest = d
+		} else if img, err := Docker.InspectImage(ctx, c.ImageID); err == nil {
+			info.ImageDigest = imageDigest(img.RepoDigests, c.Image)
+			digests[c.ImageID] = info.ImageDigest
+		}
+
+		ins, err := Docker.InspectContainer(ctx, info.Name)
+		if err != nil {
+			info.Error = err.Error()
+			out.Services = append(out.Services, info)
+			continue
+		}
+		info.State = ins.State.Status
+		info.RestartCount = ins.RestartCount
+		info.ExitCode = ins.State.ExitCode
+		info.StartedAt = dockerTime(ins.State.StartedAt)
+		info.FinishedAt = dockerTime(ins.State.FinishedAt)
+		if ins.State.Health != nil {
+			info.Health = ins.State.Health.Status
+		}
+		if ins.Config.Image != "" {
+			info.Image = ins.Config.Image
+		}
+		out.Services = append(out.Services, info)
+	}
+	return out
+}
+
+// imageDigest picks the repo digest of image's repository, or the first one.
+// Images built locally and never pushed have none.
+func imageDigest(repoDigests []string, image string) string {
+	repo := imageRepo(image)
+	for _, d := range repoDigests {
+		if strings.HasPrefix(d, repo+"@") {
+			return d
+		}
+	}
+	if len(repoDigests) > 0 {
+		return repoDigests[0]
+	}
+	return ""
+}
+
+// dockerTime parses a timestamp of the inspect document. Docker reports
+// "0001-01-01T00:00:00Z" for events that did not happen yet.
+func dockerTime(s string) *time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || t.Year() <= 1 {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func servicesDetail(t *testing.T) ServicesDetail {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	MakeServicesHandler()(rec, httptest.NewRequest(http.MethodGet, "/services?detail=true", nil))
+	var out ServicesDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	return out
+}
+
+func TestServicesDetail(t *testing.T) {
+	startFakeDocker(t, fakeDockerMux(t))
+
+	out := servicesDetail(t)
+	if out.Error != "" || len(out.Services) != 3 {
+		t.Fatalf("detail = %+v", out)
+	}
+	if s := out.Services[0]; s.Name != "secretvm" || s.Kind != "system" {
+		t.Errorf("first service = %+v", s)
+	}
+	// Containers are sorted by name: console, web.
+	web := out.Services[2]
+	if web.Name != "web" || web.ImageDigest != "ghcr.io/acme/web@sha256:222" || web.Health != "healthy" ||
+		web.RestartCount != 2 || web.StartedAt == nil || web.FinishedAt != nil || web.State != "running" {
+		t.Errorf("web = %+v", web)
+	}
+	if len(web.Ports) != 1 || web.Ports[0].PublicPort != 443 || web.Labels["com.docker.compose.service"] != "web" {
+		t.Errorf("web ports/labels = %+v %v", web.Ports, web.Labels)
+	}
+	if console := out.Services[1]; console.State != "exited" || console.ImageDigest != "" || console.Error != "" {
+		t.Errorf("console = %+v", console)
+	}
+}
+
+func TestServicesDetailDockerUnreachable(t *testing.T) {
+	prev := Docker
+	Docker = NewDockerClient(t.TempDir() + "/missing.sock")
+	defer func() { Docker = prev }()
+
+	out := servicesDetail(t)
+	if out.Error == "" || len(out.Services) != 1 || out.Services[0].Name != "secretvm" {
+		t.Errorf("detail = %+v", out)
+	}
+}
+
+func TestImageDigest(t *testing.T) {
+	digests := []string{"localhost:5000/app@sha256:1", "app@sha256:2"}
+	for image, want := range map[string]string{
+		"localhost:5000/app:v1": "localhost:5000/app@sha256:1",
+		"app":                   "app@sha256:2",
+		"app@sha256:2":          "app@sha256:2",
+		"other:latest":          "localhost:5000/app@sha256:1",
+	} {
+		if got := imageDigest(digests, image); got != want {
+			t.Errorf("imageDigest(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// pkg/tls.go
+package pkg
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"secret-vm-attest-rest-server/pkg/ratls"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RA-TLS modes selected by SECRETVM_RATLS_MODE.
+const (
+	RATLSModeOff       = "off"       // serve only CertPath/KeyPath
+	RATLSModeAlongside = "alongside" // serve the RA-TLS certificate to clients asking for RATLSServerName
+	RATLSModeOnly      = "only"      // serve only the RA-TLS certificate
+)
+
+// Certificates currently served, reported on /status. Nil when not in use.
+var (
+	ServerCert *CertReloader
+	RATLSCert  *RATLSIssuer
+)
+
+// RATLSIssuer serves a self-issued RA-TLS certificate and re-issues it, with a
+// fresh quote, once two thirds of its validity have passed. A failed renewal
+// is retried and the current certificate keeps being served until it expires.
+type RATLSIssuer struct {
+	issue func() (tls.Certificate, error)
+	cert  atomic.Pointer[tls.Certificate]
+
+	mu   sync.Mutex
+	info CertInfo
+}
+
+// NewRATLSIssuer issues the initial certificate; it fails if that fails.
+func NewRATLSIssuer(issue func() (tls.Certificate, error)) (*RATLSIssuer, error) {
+	r := &RATLSIssuer{issue: issue}
+	if err := r.Renew(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the certificate currently served.
+func (r *RATLSIssuer) GetCertificate() *tls.Certificate {
+	return r.cert.Load()
+}
+
+// Info returns details of the certificate currently served and the last renewal error.
+func (r *RATLSIssuer) Info() CertInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.info
+}
+
+// Renew issues a new certificate and swaps it in.
+func (r *RATLSIssuer) Renew() error {
+	cert, err := r.issue()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		now := time.Now().UTC()
+		r.info.LastError, r.info.LastErrorAt = err.Error(), &now
+		return err
+	}
+	r.cert.Store(&cert)
+	r.info = newCertInfo("ratls", cert.Leaf)
+	return nil
+}
+
+// renewAt returns when the current certificate is due for renewal.
+func (r *RATLSIssuer) renewAt() time.Time {
+	leaf := r.cert.Load().Leaf
+	return leaf.NotBefore.Add(leaf.NotAfter.Sub(leaf.NotBefore) * 2 / 3)
+}
+
+// Watch renews the certificate when it is due. Failed renewals are retried
+// after a tenth of the remaining validity, at least every minute and at most every hour.
+func (r *RATLSIssuer) Watch(stop <-chan struct{}) {
+	next := r.renewAt()
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := r.Renew(); err != nil {
+			retry := time.Until(r.cert.Load().Leaf.NotAfter) / 10
+			retry = min(max(retry, time.Minute), time.Hour)
+			log.Printf("RA-TLS: renewal failed, retrying in %s: %v", retry, err)
+			next = time.Now().Add(retry)
+			continue
+		}
+		info := r.Info()
+		log.Printf("RA-TLS: certificate renewed (sha256 %s, expires %s)",
+			info.FingerprintSHA256, info.NotAfter.Format(time.RFC3339))
+		next = r.renewAt()
+	}
+}
+
+// NewTLSConfig builds the server TLS configuration for the configured RA-TLS mode.
+func NewTLSConfig() (*tls.Config, error) {
+	mode := strings.ToLower(RATLSMode)
+	switch mode {
+	case RATLSModeOff, RATLSModeAlongside, RATLSModeOnly:
+	default:
+		return nil, fmt.Errorf("invalid SECRETVM_RATLS_MODE %q (want off, alongside or only)", RATLSMode)
+	}
+
+	var fileCert *CertReloader
+	if mode != RATLSModeOnly {
+		// Check if certificate and key files exist.
+		if _, err := os.Stat(CertPath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("SSL certificate file not found at %s", CertPath)
+		}
+		if _, err := os.Stat(KeyPath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("SSL key file not found at %s", KeyPath)
+		}
+		reloader, err := NewCertReloader(CertPath, KeyPath)
+		if err != nil {
+			return nil, err
+		}
+		// Renewed files on the secure mount are picked up without a restart.
+		go reloader.Watch(CertReloadInterval, nil)
+		fileCert = reloader
+		ServerCert = reloader
+	}
+
+	var raCert *RATLSIssuer
+	if mode != RATLSModeOff {
+		hostName, _ := os.Hostname()
+		issuer, err := NewRATLSIssuer(func() (tls.Certificate, error) {
+			return ratls.NewCertificate(ratls.TSMQuote(TSMReportPath),
+				[]string{RATLSServerName, hostName}, RATLSValidity)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("issue RA-TLS certificate: %w", err)
+		}
+		info := issuer.Info()
+		log.Printf("RA-TLS certificate issued (mode %s, sha256 %s, expires %s)",
+			mode, info.FingerprintSHA256, info.NotAfter.Format(time.RFC3339))
+		// Re-issued with a fresh quote well before it expires.
+		go issuer.Watch(nil)
+		raCert = issuer
+		RATLSCert = issuer
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if raCert != nil && (fileCert == nil || strings.EqualFold(hello.ServerName, RATLSServerName)) {
+				return raCert.GetCertificate(), nil
+			}
+			return fileCert.GetCertificate(hello)
+		},
+	}, nil
+}
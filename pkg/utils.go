@@ -1,15 +1,11 @@
 package pkg
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"net/http"
 	"os"
 	"os/exec"
-	"regexp"
-	"strconv"
 	"strings"
-	"time"
 )
 
 func runCommand(name string, arg ...string) (string, error) {
@@ -21,69 +17,6 @@ func runCommand(name string, arg ...string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// fetchDockerLogsWithSelector retrieves logs from a container based on
-// a provided name or numeric index. If name is non-empty, it tries to find
-// a container with exactly that name and errors if none found. If useIndex is true,
-// it tries to pick the container at that zero-based index and errors if out of range.
-// If neither name nor index is provided, it falls back to the first running container.
-func fetchDockerLogsWithSelector(name string, index int, useIndex bool, lines int) (string, error) {
-	// List running containers as "<ID> <Name>\n"
-	out, err := exec.Command("docker", "ps", "-a", "--format", "{{.ID}} {{.Names}}").Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to list Docker containers: %w", err)
-	}
-	entries := strings.Split(strings.TrimSpace(string(out)), "\n")
-	linesArg := "--tail=" + strconv.Itoa(lines)
-
-	// If a name was specified, try to find it
-	if name != "" {
-		for _, entry := range entries {
-			parts := strings.Fields(entry)
-			if len(parts) < 2 {
-				continue
-			}
-			if parts[1] == name {
-				return getContainerLogs(parts[0], linesArg)
-			}
-		}
-		return "", fmt.Errorf("no running container with name %q found", name)
-	}
-
-	// If an index was specified, try to use it
-	if useIndex {
-		if index < 0 || index >= len(entries) {
-			return "", fmt.Errorf("container index %d out of range", index)
-		}
-		parts := strings.Fields(entries[index])
-		if len(parts) >= 1 {
-			return getContainerLogs(parts[0], linesArg)
-		}
-		return "", fmt.Errorf("failed to parse container entry at index %d", index)
-	}
-
-	// No selector provided: fallback to first container
-	if len(entries) > 0 {
-		parts := strings.Fields(entries[0])
-		if len(parts) >= 1 {
-			return getContainerLogs(parts[0], linesArg)
-		}
-	}
-
-	return "", fmt.Errorf("no running containers found")
-}
-
-// getContainerLogs executes `docker logs` for the given container ID.
-func getContainerLogs(containerID, linesArg string) (string, error) {
-	var buf bytes.Buffer
-	cmd := exec.Command("docker", "logs", linesArg, containerID)
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
-		return "", err
-	}
-	return buf.String(), nil
-}
-
 // formatBytes converts bytes to a human-readable string in B, kB, MB, GB, etc.
 func formatBytes(b uint64) string {
 	const base = 1024.0
@@ -101,8 +34,9 @@ func formatBytes(b uint64) string {
 	return fmt.Sprintf("%.0f %s", f, sizes[i])
 }
 
+// getStatus reads the orchestrator status from StatusPath.
 func getStatus() (string, error) {
-	data, err := os.ReadFile("/var/run/svm_status")
+	data, err := os.ReadFile(StatusPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return "unknown", nil
@@ -120,156 +54,60 @@ func getStatus() (string, error) {
 	}
 }
 
-// LogLine represents a single log line with its parsed timestamp.
-// The Timestamp is used for sorting, while Text is the original log line.
-type LogLine struct {
-	Timestamp time.Time
-	Text      string
-}
-
-var (
-	// Matches lines like "... docker[763]: [56B blob data]".
-	// These are placeholder lines generated by systemd when the log entry
-	// was a binary blob instead of a readable string.
-	blobDataRegex = regexp.MustCompile(`\sdocker\[[0-9]+\]: \[[0-9]+B blob data\]$`)
-
-	// journalctl timestamp prefix, e.g. "Aug 27 07:58:24 ".
-	journalPrefix = regexp.MustCompile(`^[A-Z][a-z]{2}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2}\s+`)
-
-	// Docker `--timestamps` format: "2025-08-27T07:58:24.123456789Z"
-	dockerTS = "2006-01-02T15:04:05.999999999Z07:00"
-)
-
-// =======================
-// Systemd (journalctl)
-// =======================
-
-// fetchServicesLogs returns logs from systemd services `secret-vm-*`.
-// The `--no-pager` flag is required: without it, journalctl may invoke
-// a pager like `less`, which would cause the process to hang or cut output.
-func fetchServicesLogs() (string, error) {
-	var buf bytes.Buffer
-	cmd := exec.Command("journalctl",
-		"-u", "secret-vm-supervisor",
-		"-u", "secret-vm-attest-rest",
-		"--no-pager")
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
-		return "", err
-	}
-	return buf.String(), nil
-}
-
-// parseJournalOutput parses raw journalctl output into []LogLine for sorting.
-// Blob-data lines are filtered out. The text remains unchanged (no year is added).
-func parseJournalOutput(s string) []LogLine {
-	lines := strings.Split(s, "\n")
-	now := time.Now()
-	var out []LogLine
-	for _, line := range lines {
-		l := strings.TrimSpace(line)
-		if l == "" || blobDataRegex.MatchString(l) {
-			continue
-		}
-		if !journalPrefix.MatchString(l) {
-			continue
-		}
-		if len(l) < 16 {
-			continue
-		}
-		tsStr := l[:15] // "Aug 27 07:58:24"
-		parsed, err := time.ParseInLocation("Jan _2 15:04:05", tsStr, time.Local)
-		if err != nil {
-			continue
-		}
-		// Attach current year only for sorting purposes.
-		parsed = time.Date(now.Year(), parsed.Month(), parsed.Day(),
-			parsed.Hour(), parsed.Minute(), parsed.Second(), 0, time.Local)
-		out = append(out, LogLine{Timestamp: parsed, Text: l})
-	}
-	return out
-}
-
 // =======================
 // Docker logs
 // =======================
 
 // listAllDockerContainerNames returns the names of all containers
-// (running and stopped) using `docker ps -a`.
+// (running and stopped).
 func listAllDockerContainerNames() ([]string, error) {
-	out, err := exec.Command("docker", "ps", "-a", "--format", "{{.Names}}").Output()
+	containers, err := Docker.ListContainers(context.Background(), true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list Docker containers: %w", err)
 	}
-	raw := strings.Split(strings.TrimSpace(string(out)), "\n")
 	var names []string
-	for _, n := range raw {
-		n = strings.TrimSpace(n)
-		if n != "" {
+	for _, c := range containers {
+		if n := c.Name(); n != "" {
 			names = append(names, n)
 		}
 	}
 	return names, nil
 }
 
-// formatDockerAsJournal converts a docker log line into a journalctl-like format:
-// "Aug 27 07:58:24 <host> <container>[pid]: <message>"
-func formatDockerAsJournal(ts time.Time, host, container string, pid int, msg string) string {
-	return fmt.Sprintf("%s %s %s[%d]: %s", ts.Local().Format("Jan 2 15:04:05"), host, container, pid, msg)
-}
+// fetchDockerLogsForContainer retrieves logs for a specific container with
+// timestamps, keeping the container's stdout and stderr apart.
+func fetchDockerLogsForContainer(container string, lines int, hostName string, f LogFilter) ([]LogEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerTimeout)
+	defer cancel()
 
-// fetchDockerLogsForContainer retrieves logs for a specific container with timestamps.
-// It converts docker ISO timestamps into journalctl-like lines.
-func fetchDockerLogsForContainer(container string, lines int, hostName string) ([]LogLine, error) {
-	linesArg := "--tail=" + strconv.Itoa(lines)
-	out, err := exec.Command("docker", "logs", "--timestamps", linesArg, container).CombinedOutput()
+	info, err := Docker.InspectContainer(ctx, container)
 	if err != nil {
 		return nil, err
 	}
-	text := strings.TrimRight(string(out), "\n")
-	if text == "" {
-		return nil, nil
+	rc, err := Docker.ContainerLogs(ctx, container, DockerLogsOptions{Tail: lines, Since: f.Since, Until: f.Until})
+	if err != nil {
+		return nil, err
 	}
+	defer rc.Close()
 
-	// Get container init PID once; if it fails, fallback to 0
-	pid := getContainerInitPID(container)
-
-	var res []LogLine
-	for _, l := range strings.Split(text, "\n") {
-		if l == "" {
-			continue
-		}
-		space := strings.IndexByte(l, ' ')
-		if space <= 0 {
-			continue
-		}
-		tsRaw := l[:space]
-		msg := strings.TrimSpace(l[space+1:])
-
-		ts, err := time.Parse(dockerTS, tsRaw)
-		if err != nil {
-			if ts2, err2 := time.Parse(time.RFC3339, tsRaw); err2 == nil {
-				ts = ts2
-			} else {
-				continue
-			}
-		}
-		jline := formatDockerAsJournal(ts, hostName, container, pid, msg)
-		res = append(res, LogLine{Timestamp: ts.Local(), Text: jline})
-	}
-	return res, nil
+	var res []LogEntry
+	reader := newDockerLogReader(container, info.State.Pid, hostName, func(e LogEntry) error {
+		res = append(res, e)
+		return nil
+	})
+	err = reader.Read(rc, info.Config.Tty)
+	return res, err
 }
 
 // fetchDockerLogsAll retrieves logs from all containers (running and stopped).
-func fetchDockerLogsAll(lines int, hostName string) ([]LogLine, error) {
+func fetchDockerLogsAll(lines int, hostName string, f LogFilter) ([]LogEntry, error) {
 	names, err := listAllDockerContainerNames()
 	if err != nil {
 		return nil, err
 	}
-	var combined []LogLine
+	var combined []LogEntry
 	for _, name := range names {
-		ll, err := fetchDockerLogsForContainer(name, lines, hostName)
+		ll, err := fetchDockerLogsForContainer(name, lines, hostName, f)
 		if err != nil {
 			continue
 		}
@@ -277,35 +115,3 @@ func fetchDockerLogsAll(lines int, hostName string) ([]LogLine, error) {
 	}
 	return combined, nil
 }
-
-// writeLogsResponse writes log lines as plain text in journalctl format.
-// Blob-data lines are filtered out again for safety.
-func writeLogsResponse(w http.ResponseWriter, lines []LogLine) {
-	var b strings.Builder
-	for _, l := range lines {
-		if blobDataRegex.MatchString(l.Text) {
-			continue
-		}
-		b.WriteString(l.Text)
-		b.WriteByte('\n')
-	}
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte(b.String()))
-}
-
-// getContainerInitPID returns the host PID of the container's init process.
-// We use `docker inspect -f '{{.State.Pid}}' <container>`.
-func getContainerInitPID(container string) int {
-	out, err := exec.Command("docker", "inspect", "-f", "{{.State.Pid}}", container).Output()
-	if err != nil {
-		return 0
-	}
-	s := strings.TrimSpace(string(out))
-	pid, err := strconv.Atoi(s)
-	if err != nil {
-		return 0
-	}
-	return pid
-}
@@ -129,6 +129,10 @@ echo -e "${YELLOW}SSL Verification: $(if [[ "$SKIP_SSL" == "true" ]]; then echo
 # Test /status
 test_endpoint "/status" "Status"
 
+# Test /healthz and /readyz
+test_endpoint "/healthz" "Liveness"
+test_endpoint "/readyz" "Readiness"
+
 # Test /gpu
 test_endpoint "/gpu" "GPU Attestation"
 